@@ -0,0 +1,256 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast
+
+import "time"
+
+// InstructionOption sets operator, presence or other property of instruction.
+type InstructionOption func(*Instruction)
+
+// Optional sets optional presence of instruction.
+func Optional() InstructionOption {
+	return func(i *Instruction) { i.Presence = PresenceOptional }
+}
+
+// Constant sets constant operator with value.
+func Constant(value interface{}) InstructionOption {
+	return operator(OperatorConstant, value)
+}
+
+// Default sets default operator with value, the value of optional instruction can be nil.
+func Default(value interface{}) InstructionOption {
+	return operator(OperatorDefault, value)
+}
+
+// Copy sets copy operator.
+func Copy() InstructionOption {
+	return operator(OperatorCopy, nil)
+}
+
+// Increment sets increment operator.
+func Increment() InstructionOption {
+	return operator(OperatorIncrement, nil)
+}
+
+// Delta sets delta operator.
+func Delta() InstructionOption {
+	return operator(OperatorDelta, nil)
+}
+
+// Tail sets tail operator.
+func Tail() InstructionOption {
+	return operator(OperatorTail, nil)
+}
+
+// InitialValue sets initial value of copy, increment, delta or tail operator.
+func InitialValue(value interface{}) InstructionOption {
+	return func(i *Instruction) { i.Value = value }
+}
+
+// Dictionary sets dictionary of operator.
+func Dictionary(name string) InstructionOption {
+	return func(i *Instruction) { i.Dictionary = name }
+}
+
+// Key sets dictionary key of operator.
+func Key(key string) InstructionOption {
+	return func(i *Instruction) { i.Key = key }
+}
+
+// TypeRef sets application type of group or sequence.
+func TypeRef(name string) InstructionOption {
+	return func(i *Instruction) { i.TypeRef = name }
+}
+
+// Unit sets unit of timestamp or time, it has to be second, millisecond,
+// microsecond or nanosecond.
+func Unit(unit time.Duration) InstructionOption {
+	return func(i *Instruction) { i.Unit = unit }
+}
+
+// Exponent sets individual operator of decimal exponent.
+func Exponent(opts ...InstructionOption) InstructionOption {
+	return func(i *Instruction) {
+		i.Instructions = append(i.Instructions, newInstructionOf(TypeExponent, "", 0, opts))
+	}
+}
+
+// Mantissa sets individual operator of decimal mantissa.
+func Mantissa(opts ...InstructionOption) InstructionOption {
+	return func(i *Instruction) {
+		i.Instructions = append(i.Instructions, newInstructionOf(TypeMantissa, "", 0, opts))
+	}
+}
+
+func operator(op InstructionOperator, value interface{}) InstructionOption {
+	return func(i *Instruction) {
+		i.Operator = op
+		i.Value = value
+	}
+}
+
+func newInstructionOf(typ InstructionType, name string, id uint, opts []InstructionOption) *Instruction {
+	instruction := &Instruction{
+		ID:       id,
+		Name:     name,
+		Type:     typ,
+		Operator: OperatorNone,
+		Presence: PresenceMandatory,
+	}
+	for _, opt := range opts {
+		opt(instruction)
+	}
+	return instruction
+}
+
+// Builder builds instructions of template, group or sequence. Templates have
+// to be compiled by Compile before they are passed to Encoder or Decoder.
+//
+//	tpl := fast.NewTemplate(1, "Quote").
+//		Uint32("Price", 44, fast.Copy()).
+//		Sequence("Entries", 268, fast.NewInstructions().
+//			String("Symbol", 55, fast.Optional()),
+//		).
+//		Template()
+type Builder struct {
+	template     *Template
+	instructions []*Instruction
+}
+
+// NewTemplate returns builder of template.
+func NewTemplate(id uint, name string) *Builder {
+	return &Builder{template: &Template{ID: id, Name: name}}
+}
+
+// NewInstructions returns builder of group or sequence instructions.
+func NewInstructions() *Builder {
+	return &Builder{}
+}
+
+// Uint32 adds uInt32 field.
+func (b *Builder) Uint32(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeUint32, name, id, opts))
+}
+
+// Int32 adds int32 field.
+func (b *Builder) Int32(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeInt32, name, id, opts))
+}
+
+// Uint64 adds uInt64 field.
+func (b *Builder) Uint64(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeUint64, name, id, opts))
+}
+
+// Int64 adds int64 field.
+func (b *Builder) Int64(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeInt64, name, id, opts))
+}
+
+// String adds ascii string field.
+func (b *Builder) String(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeASCIIString, name, id, opts))
+}
+
+// Unicode adds unicode string field.
+func (b *Builder) Unicode(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeUnicodeString, name, id, opts))
+}
+
+// ByteVector adds byte vector field.
+func (b *Builder) ByteVector(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeByteVector, name, id, opts))
+}
+
+// Decimal adds decimal field. Operators of exponent and mantissa are set by
+// Exponent and Mantissa options.
+func (b *Builder) Decimal(name string, id uint, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeDecimal, name, id, opts)
+	if len(instruction.Instructions) > 0 {
+		completeDecimal(instruction)
+	}
+	return b.add(instruction)
+}
+
+// Boolean adds boolean field.
+func (b *Builder) Boolean(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeBoolean, name, id, opts))
+}
+
+// Enum adds enum field with names of elements.
+func (b *Builder) Enum(name string, id uint, elements []string, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeEnum, name, id, opts)
+	instruction.Elements = elements
+	return b.add(instruction)
+}
+
+// Set adds set field with names of elements.
+func (b *Builder) Set(name string, id uint, elements []string, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeSet, name, id, opts)
+	instruction.Elements = elements
+	return b.add(instruction)
+}
+
+// Timestamp adds timestamp field, unit is set by Unit option.
+func (b *Builder) Timestamp(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeTimestamp, name, id, opts))
+}
+
+// Time adds time of day field, unit is set by Unit option.
+func (b *Builder) Time(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeTime, name, id, opts))
+}
+
+// Date adds date field.
+func (b *Builder) Date(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeDate, name, id, opts))
+}
+
+// Length adds length field of sequence. It has to be the first field of sequence.
+func (b *Builder) Length(name string, id uint, opts ...InstructionOption) *Builder {
+	return b.add(newInstructionOf(TypeLength, name, id, opts))
+}
+
+// Group adds group of fields.
+func (b *Builder) Group(name string, id uint, fields *Builder, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeGroup, name, id, opts)
+	instruction.Instructions = fields.instructions
+	return b.add(instruction)
+}
+
+// BitGroup adds group of boolean fields, which is encoded as bits of integer.
+func (b *Builder) BitGroup(name string, id uint, fields *Builder, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeBitGroup, name, id, opts)
+	instruction.Instructions = fields.instructions
+	return b.add(instruction)
+}
+
+// Sequence adds sequence of fields. Length without name is added if fields
+// do not begin with Length.
+func (b *Builder) Sequence(name string, id uint, fields *Builder, opts ...InstructionOption) *Builder {
+	instruction := newInstructionOf(TypeSequence, name, id, opts)
+	instruction.Instructions = fields.instructions
+	completeSequence(instruction)
+	return b.add(instruction)
+}
+
+// TemplateRef adds reference to template. The reference is dynamic if name is empty.
+func (b *Builder) TemplateRef(name string) *Builder {
+	return b.add(newInstructionOf(TypeTemplateRef, name, 0, nil))
+}
+
+// Template returns built template.
+func (b *Builder) Template() *Template {
+	if b.template == nil {
+		b.template = &Template{}
+	}
+	b.template.Instructions = b.instructions
+	return b.template
+}
+
+func (b *Builder) add(instruction *Instruction) *Builder {
+	b.instructions = append(b.instructions, instruction)
+	return b
+}
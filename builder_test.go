@@ -0,0 +1,163 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+)
+
+// builtTemplates returns the first templates of testdata/test.xml defined by Builder.
+func builtTemplates() []*fast.Template {
+	dictionaryA := fast.NewTemplate(12, "DictionaryA").
+		Uint32("Shared", 1, fast.Copy()).
+		Uint32("Global", 2, fast.Copy(), fast.Dictionary("global"), fast.Key("GlobalKey")).
+		Template()
+	dictionaryA.Dictionary = "template"
+
+	return []*fast.Template{
+		fast.NewTemplate(1, "Decimal").
+			Decimal("CopyDecimal", 1, fast.Optional(), fast.Copy()).
+			Decimal("MandatoryDecimal", 2).
+			Decimal("IndividualDecimal", 3,
+				fast.Exponent(fast.Default(int32(0))),
+				fast.Mantissa(fast.Delta()),
+			).
+			Decimal("IndividualDecimalOpt", 4, fast.Optional(),
+				fast.Exponent(fast.Default(int32(0))),
+				fast.Mantissa(fast.Delta()),
+			).
+			Template(),
+		fast.NewTemplate(2, "Sequence").
+			Uint32("TestData", 1).
+			Sequence("OuterSequence", 0, fast.NewInstructions().
+				Length("NoOuterSequence", 2).
+				Uint32("OuterTestData", 3).
+				Sequence("InnerSequence", 0, fast.NewInstructions().
+					Length("NoInnerSequence", 4).
+					Uint32("InnerTestData", 5),
+					fast.Optional(),
+				),
+			).
+			Sequence("NextOuterSequence", 0, fast.NewInstructions().
+				Length("NoNextOuterSequence", 6).
+				Uint32("NextOuterTestData", 7),
+			).
+			Template(),
+		fast.NewTemplate(3, "ByteVector").
+			ByteVector("MandatoryVector", 1).
+			ByteVector("OptionalVector", 2, fast.Optional()).
+			Template(),
+		fast.NewTemplate(4, "String").
+			String("MandatoryAscii", 1).
+			String("OptionalAscii", 2, fast.Optional()).
+			Unicode("MandatoryUnicode", 3).
+			Unicode("OptionalUnicode", 4, fast.Optional()).
+			Template(),
+		fast.NewTemplate(5, "Integer").
+			Uint32("MandatoryUint32", 1).
+			Uint32("OptionalUint32", 2, fast.Optional()).
+			Uint64("MandatoryUint64", 3).
+			Uint64("OptionalUint64", 4, fast.Optional()).
+			Int32("MandatoryInt32", 5).
+			Int32("OptionalInt32", 6, fast.Optional()).
+			Int64("MandatoryInt64", 7).
+			Int64("OptionalInt64", 8, fast.Optional()).
+			Template(),
+		fast.NewTemplate(6, "Group").
+			Uint32("TestData", 1).
+			Group("OuterGroup", 0, fast.NewInstructions().
+				Uint32("OuterTestData", 2).
+				Group("InnerGroup", 0, fast.NewInstructions().
+					Uint32("InnerTestData", 3),
+					fast.Optional(),
+				),
+			).
+			Template(),
+		fast.NewTemplate(7, "Tail").
+			String("TailAscii", 1, fast.Tail()).
+			Unicode("TailUnicode", 2, fast.Optional(), fast.Tail(), fast.InitialValue("abc")).
+			ByteVector("TailVector", 3, fast.Tail()).
+			Template(),
+		fast.NewTemplate(8, "Delta").
+			String("DeltaAscii", 1, fast.Delta()).
+			Unicode("DeltaUnicode", 2, fast.Optional(), fast.Delta(), fast.InitialValue("abc")).
+			ByteVector("DeltaVector", 3, fast.Delta()).
+			Uint32("DeltaUint32", 4, fast.Delta()).
+			Template(),
+		fast.NewTemplate(9, "Header").
+			Uint32("HeaderSeqNum", 34).
+			Template(),
+		fast.NewTemplate(10, "StaticReference").
+			TemplateRef("Header").
+			String("RefData", 1).
+			Template(),
+		fast.NewTemplate(11, "DynamicReference").
+			Uint32("OuterData", 1).
+			TemplateRef("").
+			Template(),
+		dictionaryA,
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	ftpl, err := os.Open("testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	parsed, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	built := builtTemplates()
+	if err = fast.Compile(built...); err != nil {
+		t.Fatal(err)
+	}
+	for i, tpl := range built {
+		if !reflect.DeepEqual(tpl, parsed[i]) {
+			t.Fatal("template", tpl.Name, "is not equal to parsed template")
+		}
+	}
+}
+
+func TestBuilderSequenceLength(t *testing.T) {
+	tpl := fast.NewTemplate(1, "Implicit").
+		Sequence("Entries", 0, fast.NewInstructions().Uint32("Price", 1), fast.Optional()).
+		Template()
+	if err := fast.Compile(tpl); err != nil {
+		t.Fatal(err)
+	}
+
+	length := tpl.Instructions[0].Instructions[0]
+	if length.Type != fast.TypeLength || length.Presence != fast.PresenceOptional {
+		t.Fatal("expected optional length, got", length)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		tpl *fast.Template
+		err error
+	}{
+		{fast.NewTemplate(1, "S2").Uint32("Data", 1, fast.Tail()).Template(), fast.ErrS2},
+		{fast.NewTemplate(1, "S3").Uint32("Data", 1, fast.Default(1)).Template(), fast.ErrS3},
+		{fast.NewTemplate(1, "S4").String("Data", 1, fast.Constant(nil)).Template(), fast.ErrS4},
+		{fast.NewTemplate(1, "S5").String("Data", 1, fast.Default(nil)).Template(), fast.ErrS5},
+		{fast.NewTemplate(1, "D8").TemplateRef("Unknown").Template(), fast.ErrD8},
+		{fast.NewTemplate(1, "Valid").String("Data", 1, fast.Optional(), fast.Default(nil)).Template(), nil},
+	}
+	for _, c := range cases {
+		if err := fast.Compile(c.tpl); !errors.Is(err, c.err) {
+			t.Fatal(c.tpl.Name, "expected", c.err, "got", err)
+		}
+	}
+}
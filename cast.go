@@ -6,6 +6,7 @@ package fast
 
 import (
 	"math"
+	"reflect"
 	"strconv"
 	"strings"
 	"unicode"
@@ -14,9 +15,63 @@ import (
 const (
 	uintSize = 32 << (^uint(0) >> 32 & 1)
 	maxInt = 1<<(uintSize-1) - 1
+	minInt = -1 << (uintSize - 1)
 	maxUint = 1<<uintSize - 1
 )
 
+// castValue converts value of application field to type of instruction value.
+func (i *Instruction) castValue(value interface{}) (interface{}, error) {
+	var err error
+	switch i.Type {
+	case TypeUint32, TypeLength:
+		if _, ok := value.(uint32); !ok {
+			var dst uint32
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	case TypeUint64:
+		if _, ok := value.(uint64); !ok {
+			var dst uint64
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	case TypeInt32, TypeExponent:
+		if _, ok := value.(int32); !ok {
+			var dst int32
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	case TypeInt64, TypeMantissa:
+		if _, ok := value.(int64); !ok {
+			var dst int64
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	case TypeASCIIString:
+		if s, ok := value.(string); ok {
+			return value, checkASCII(s)
+		}
+		var dst string
+		err = castStringToASCII(value, &dst)
+		return dst, err
+	case TypeUnicodeString:
+		if _, ok := value.(string); !ok {
+			var dst string
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	case TypeByteVector:
+		if _, ok := value.([]byte); !ok {
+			var dst []byte
+			err = castTo(value, &dst)
+			return dst, err
+		}
+	}
+	return value, nil
+}
+
+// castTo converts src to value pointed to by dst. Named types are converted by
+// kind of src. It returns ErrD1 if types are not compatible.
 func castTo(src, dst interface{}) error {
 	switch src.(type) {
 	case []byte:
@@ -33,15 +88,34 @@ func castTo(src, dst interface{}) error {
 		return castIntTo(src.(int64), dst)
 	case float64:
 		return castFloatTo(src.(float64), dst)
+	case Decimal:
+		return castDecimalTo(src.(Decimal), dst)
 	}
 
-	return nil
+	rv := reflect.ValueOf(src)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return castIntTo(rv.Int(), dst)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return castUintTo(rv.Uint(), dst)
+	case reflect.Float32, reflect.Float64:
+		return castFloatTo(rv.Float(), dst)
+	case reflect.String:
+		return castStringTo(rv.String(), dst)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return castByteVectorTo(rv.Bytes(), dst)
+		}
+	}
+	return ErrD1
 }
 
 func castByteVectorTo(src []byte, dst interface{}) (err error) {
 	switch dst.(type) {
 	case *string:
 		*dst.(*string) = string(src)
+	case *[]byte:
+		*dst.(*[]byte) = append([]byte(nil), src...)
 	default:
 		err = ErrD10
 	}
@@ -50,6 +124,16 @@ func castByteVectorTo(src []byte, dst interface{}) (err error) {
 }
 
 func castStringTo(src string, dst interface{}) (err error) {
+	switch dst.(type) {
+	case *[]byte:
+		*dst.(*[]byte) = []byte(src)
+		return
+	case *string:
+		*dst.(*string) = src
+		return
+	}
+
+	// leading and trailing whitespaces are ignored by conversion to numbers
 	src = strings.TrimSpace(src)
 	switch dst.(type) {
 	case *int:
@@ -94,14 +178,11 @@ func castStringTo(src string, dst interface{}) (err error) {
 		var tmp float64
 		tmp, err = strconv.ParseFloat(src, 32)
 		*dst.(*float32) = float32(tmp)
-	case *[]byte:
-		*dst.(*[]byte) = []byte(src)
-	case *string:
-		*dst.(*string) = src
+	default:
+		return ErrD1
 	}
 
-	checkStringErr(err, dst)
-	return
+	return checkStringErr(err, dst)
 }
 
 func castUintTo(src uint64, dst interface{}) (err error) {
@@ -161,6 +242,8 @@ func castUintTo(src uint64, dst interface{}) (err error) {
 		err = ErrD10
 	case *string:
 		*dst.(*string) = strconv.FormatUint(src, 10)
+	default:
+		err = ErrD1
 	}
 	return
 }
@@ -169,46 +252,49 @@ func castIntTo(src int64, dst interface{}) (err error) {
 	switch dst.(type) {
 	case *int:
 		*dst.(*int) = int(src)
-		if src > maxInt {
+		if src > maxInt || src < minInt {
 			err = ErrR4
 		}
 	case *int64:
 		*dst.(*int64) = src
 	case *int32:
 		*dst.(*int32) = int32(src)
-		if src > math.MaxInt32 {
+		if src > math.MaxInt32 || src < math.MinInt32 {
 			err = ErrR4
 		}
 	case *int16:
 		*dst.(*int16) = int16(src)
-		if src > math.MaxInt16 {
+		if src > math.MaxInt16 || src < math.MinInt16 {
 			err = ErrR4
 		}
 	case *int8:
 		*dst.(*int8) = int8(src)
-		if src > math.MaxInt8 {
+		if src > math.MaxInt8 || src < math.MinInt8 {
 			err = ErrR4
 		}
 	case *uint:
 		*dst.(*uint) = uint(src)
-		if uint(src) > maxUint {
+		if src < 0 || uint64(src) > maxUint {
 			err = ErrR4
 		}
 	case *uint64:
 		*dst.(*uint64) = uint64(src)
+		if src < 0 {
+			err = ErrR4
+		}
 	case *uint32:
 		*dst.(*uint32) = uint32(src)
-		if src > math.MaxUint32 {
+		if src > math.MaxUint32 || src < 0 {
 			err = ErrR4
 		}
 	case *uint16:
 		*dst.(*uint16) = uint16(src)
-		if src > math.MaxUint16 {
+		if src > math.MaxUint16 || src < 0 {
 			err = ErrR4
 		}
 	case *uint8:
 		*dst.(*uint8) = uint8(src)
-		if src > math.MaxUint8 {
+		if src > math.MaxUint8 || src < 0 {
 			err = ErrR4
 		}
 	case *float64:
@@ -219,6 +305,8 @@ func castIntTo(src int64, dst interface{}) (err error) {
 		err = ErrD10
 	case *string:
 		*dst.(*string) = strconv.FormatInt(src, 10)
+	default:
+		err = ErrD1
 	}
 	return
 }
@@ -230,6 +318,9 @@ func castFloatTo(src float64, dst interface{}) (err error) {
 		if err != nil {
 			return
 		}
+		if src < 0 {
+			return castIntTo(int64(src), dst)
+		}
 		err = castUintTo(uint64(src), dst)
 	case *float64:
 		*dst.(*float64) = src
@@ -239,6 +330,43 @@ func castFloatTo(src float64, dst interface{}) (err error) {
 		err = ErrD10
 	case *string:
 		*dst.(*string) = strconv.FormatFloat(src, 'f', -1, 64)
+	default:
+		err = ErrD1
+	}
+	return
+}
+
+// castDecimalTo converts decimal to integer, float or string. It returns ErrR5
+// if decimal is not integer or does not fit the integer type.
+func castDecimalTo(src Decimal, dst interface{}) (err error) {
+	switch dst.(type) {
+	case *int, *int64, *int32, *int16, *int8, *uint, *uint64, *uint32, *uint16, *uint8:
+		d := src.ToDecimal()
+		if !d.IsInteger() {
+			return ErrR5
+		}
+		i := d.BigInt()
+		switch {
+		case i.IsInt64():
+			err = castIntTo(i.Int64(), dst)
+		case i.IsUint64():
+			err = castUintTo(i.Uint64(), dst)
+		default:
+			err = ErrR5
+		}
+		if err == ErrR4 {
+			err = ErrR5
+		}
+	case *float64:
+		*dst.(*float64) = src.Float64()
+	case *float32:
+		*dst.(*float32) = float32(src.Float64())
+	case *[]byte:
+		err = ErrD10
+	case *string:
+		*dst.(*string) = src.String()
+	default:
+		err = ErrD1
 	}
 	return
 }
@@ -248,22 +376,32 @@ func castStringToASCII(src interface{}, dst *string) (err error) {
 	if err != nil {
 		return
 	}
-	for i := 0; i < len(*dst); i++ {
-		if (*dst)[i] > unicode.MaxASCII {
+	return checkASCII(*dst)
+}
+
+// checkASCII returns ErrR3 if string contains characters outside the ASCII character set.
+func checkASCII(s string) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
 			return ErrR3
 		}
 	}
-	return
+	return nil
 }
 
+// checkFloatErr returns ErrR5 if float is not integer and ErrR4 if it does
+// not fit the 64 bits integer types.
 func checkFloatErr(src float64) error {
-	if expDecimal(src) > 0 {
+	if math.IsNaN(src) || math.IsInf(src, 0) || expDecimal(src) < 0 {
 		return ErrR5
 	}
+	if src < math.MinInt64 || src >= math.MaxUint64 {
+		return ErrR4
+	}
 	return nil
 }
 
-func checkStringErr(err error, dst interface{}) {
+func checkStringErr(err error, dst interface{}) error {
 	switch dst.(type) {
 	case *int, *int64, *int32, *int16, *int8, *uint, *uint64, *uint32, *uint16, *uint8:
 		if e, ok := err.(*strconv.NumError); ok {
@@ -282,4 +420,5 @@ func checkStringErr(err error, dst interface{}) {
 			}
 		}
 	}
+	return err
 }
@@ -0,0 +1,164 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+)
+
+const xmlCast = `
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Cast" id="1">
+		<uInt32 name="Count" id="1"/>
+		<int64 name="Price" id="2"/>
+		<string name="Symbol" id="3"/>
+		<byteVector name="Raw" id="4"/>
+		<decimal name="Qty" id="5"/>
+	</template>
+</templates>`
+
+type quantity int
+
+type castMessage struct {
+	TemplateID uint32 `fast:"*"`
+	Count      int
+	Price      string
+	Symbol     []byte
+	Raw        string
+	Qty        *quantity
+}
+
+type (
+	castIntCount struct {
+		TemplateID uint `fast:"*"`
+		Count      int
+	}
+	castFloatCount struct {
+		TemplateID uint `fast:"*"`
+		Count      float64
+	}
+	castBoolCount struct {
+		TemplateID uint `fast:"*"`
+		Count      bool
+	}
+	castInt8Count struct {
+		TemplateID uint `fast:"*"`
+		Count      int8
+	}
+	castStringPrice struct {
+		TemplateID uint `fast:"*"`
+		Price      string
+	}
+	castUintPrice struct {
+		TemplateID uint `fast:"*"`
+		Price      uint
+	}
+	castStringSymbol struct {
+		TemplateID uint `fast:"*"`
+		Symbol     string
+	}
+	castIntRaw struct {
+		TemplateID uint `fast:"*"`
+		Raw        int
+	}
+	castIntQty struct {
+		TemplateID uint `fast:"*"`
+		Qty        int
+	}
+)
+
+func TestCast(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qty := quantity(300)
+	msg := castMessage{
+		TemplateID: 1,
+		Count:      2,
+		Price:      " -5 ",
+		Symbol:     []byte("ABC"),
+		Raw:        "raw",
+		Qty:        &qty,
+	}
+
+	var buf bytes.Buffer
+	if err = fast.NewEncoder(&buf, tpls...).Encode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	// pmap, tid, count, price, symbol, raw, qty exponent and mantissa
+	expect := []byte{0xc0, 0x81, 0x82, 0xfb, 0x41, 0x42, 0xc3, 0x83, 0x72, 0x61, 0x77, 0x80, 0x02, 0xac}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+
+	var decoded castMessage
+	if err = fast.NewDecoder(&buf, tpls...).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	msg.Price = "-5"
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+	}
+}
+
+func TestCastEncodeErrors(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		msg interface{}
+		err error
+	}{
+		{&castIntCount{1, -1}, fast.ErrR4},
+		{&castFloatCount{1, 1.5}, fast.ErrR5},
+		{&castStringPrice{1, "1x"}, fast.ErrD11},
+		{&castStringSymbol{1, "Ä"}, fast.ErrR3},
+		{&castIntRaw{1, 1}, fast.ErrD10},
+		{&castBoolCount{1, true}, fast.ErrD1},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		err = fast.NewEncoder(&buf, tpls...).Encode(c.msg)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%T: expected %v, got %v", c.msg, c.err, err)
+		}
+	}
+}
+
+func TestCastDecodeErrors(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// count 300, price -5, symbol "A", empty raw, qty 1.5
+	data := []byte{0xc0, 0x81, 0x02, 0xac, 0xfb, 0xc1, 0x80, 0xff, 0x8f}
+	cases := []struct {
+		msg interface{}
+		err error
+	}{
+		{&castInt8Count{}, fast.ErrR4},
+		{&castUintPrice{}, fast.ErrR4},
+		{&castIntQty{}, fast.ErrR5},
+		{&castIntRaw{}, fast.ErrD10},
+		{&castBoolCount{}, fast.ErrD1},
+	}
+	for _, c := range cases {
+		err = fast.NewDecoder(bytes.NewReader(data), tpls...).Decode(c.msg)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%T: expected %v, got %v", c.msg, c.err, err)
+		}
+	}
+}
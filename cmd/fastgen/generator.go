@@ -0,0 +1,428 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/co11ter/goFAST"
+)
+
+const tagTemplateRef = "templateRef"
+
+type fieldKind int
+
+const (
+	kindValue fieldKind = iota
+	kindGroup
+	kindSequence
+	kindTemplateRef
+)
+
+// field is a field of generated struct.
+type field struct {
+	kind     fieldKind
+	id       uint
+	name     string // name of instruction
+	goName   string
+	goType   string // type of value, element type for sequence
+	optional bool
+}
+
+// structType is a generated struct of template, group or sequence element.
+type structType struct {
+	name   string
+	doc    string
+	tpl    *fast.Template // nil for nested struct
+	fields []*field
+}
+
+type generator struct {
+	buf      bytes.Buffer
+	structs  []*structType
+	names    map[string]bool // names of package types
+	maxDepth int             // max count of nested segments
+	hasTime  bool            // true if time package is imported
+}
+
+// generate returns formatted Go code of templates.
+func generate(tpls []*fast.Template, pkg, source string) ([]byte, error) {
+	g := &generator{
+		names:    map[string]bool{"segment": true, "generic": true, "lockState": true},
+		maxDepth: 1,
+	}
+	for _, tpl := range tpls {
+		st := g.newStruct(tpl.Name, tpl.Instructions, 0)
+		st.tpl = tpl
+		st.doc = fmt.Sprintf("is a message of template %d.", tpl.ID)
+	}
+
+	g.printf("// Code generated by fastgen from %s. DO NOT EDIT.\n\n", source)
+	g.printf("package %s\n\n", pkg)
+	if g.hasTime {
+		g.printf("import (\n\"time\"\n\n\"github.com/co11ter/goFAST\"\n)\n\n")
+	} else {
+		g.printf("import \"github.com/co11ter/goFAST\"\n\n")
+	}
+	g.printCommon()
+	for _, st := range g.structs {
+		g.printStruct(st)
+	}
+
+	code, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("can not format generated code: %v", err)
+	}
+	return code, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) newStruct(name string, instructions []*fast.Instruction, depth int) *structType {
+	if depth > g.maxDepth {
+		g.maxDepth = depth
+	}
+
+	st := &structType{name: uniqueName(goName(name), g.names)}
+	g.structs = append(g.structs, st)
+
+	used := map[string]bool{"TemplateID": true}
+	for _, in := range instructions {
+		f := &field{
+			id:       in.ID,
+			name:     in.Name,
+			goName:   goName(in.Name),
+			optional: in.Presence == fast.PresenceOptional,
+		}
+
+		switch in.Type {
+		case fast.TypeNull, fast.TypeLength:
+			continue
+		case fast.TypeTemplateRef:
+			f.kind = kindTemplateRef
+			f.name = tagTemplateRef
+			f.goName = "TemplateRef"
+			f.goType = "fast.Message"
+			if depth+1 > g.maxDepth {
+				g.maxDepth = depth + 1
+			}
+		case fast.TypeGroup:
+			nested := g.newStruct(st.name+f.goName, in.Instructions, depth+1)
+			nested.doc = "is a group " + in.Name + " of " + st.name + "."
+			f.kind = kindGroup
+			f.goType = nested.name
+		case fast.TypeBitGroup:
+			nested := g.newStruct(st.name+f.goName, in.Instructions, depth+1)
+			nested.doc = "is a bit group " + in.Name + " of " + st.name + "."
+			f.kind = kindGroup
+			f.goType = nested.name
+		case fast.TypeSequence:
+			nested := g.newStruct(st.name+f.goName, in.Instructions[1:], depth+1)
+			nested.doc = "is an element of sequence " + in.Name + " of " + st.name + "."
+			f.kind = kindSequence
+			f.goType = nested.name
+		default:
+			f.goType = valueType(in.Type)
+			if f.goType == "time.Time" {
+				g.hasTime = true
+			}
+		}
+
+		f.goName = uniqueName(f.goName, used)
+		st.fields = append(st.fields, f)
+	}
+	return st
+}
+
+// printCommon prints types shared by all messages.
+func (g *generator) printCommon() {
+	g.printf(`// segment is a message or nested struct of group or sequence element.
+type segment interface {
+	setTemplateID(uint)
+	templateID() uint
+	setValue(*fast.Field)
+	getValue(*fast.Field)
+	setLength(*fast.Field)
+	getLength(*fast.Field)
+	lock(*fast.Field) segment
+}
+
+// lockState is a stack of locked segments of message.
+type lockState struct {
+	segments [%d]segment
+	depth    int
+	generic  int // count of locks inside generic message of template reference
+}
+
+func (s *lockState) active(root segment) segment {
+	if s.depth == 0 {
+		return root
+	}
+	return s.segments[s.depth-1]
+}
+
+func (s *lockState) lock(root segment, field *fast.Field) bool {
+	active := s.active(root)
+	if g, ok := active.(generic); ok {
+		if !g.msg.Lock(field) {
+			return false
+		}
+		s.generic++
+		return true
+	}
+
+	next := active.lock(field)
+	if next == nil {
+		return false
+	}
+	s.segments[s.depth] = next
+	s.depth++
+	return true
+}
+
+func (s *lockState) unlock() {
+	if s.generic > 0 {
+		s.generic--
+		s.segments[s.depth-1].(generic).msg.Unlock()
+		return
+	}
+	s.depth--
+	s.segments[s.depth] = nil
+}
+
+// generic is a segment of dynamic template reference.
+type generic struct {
+	msg *fast.Message
+}
+
+func (g generic) setTemplateID(tid uint)        { g.msg.SetTemplateID(tid) }
+func (g generic) templateID() uint              { return g.msg.GetTemplateID() }
+func (g generic) setValue(field *fast.Field)    { g.msg.SetValue(field) }
+func (g generic) getValue(field *fast.Field)    { g.msg.GetValue(field) }
+func (g generic) setLength(field *fast.Field)   { g.msg.SetLength(field) }
+func (g generic) getLength(field *fast.Field)   { g.msg.GetLength(field) }
+func (g generic) lock(*fast.Field) segment      { return nil }
+
+`, g.maxDepth)
+}
+
+func (g *generator) printStruct(st *structType) {
+	g.printf("// %s %s\n", st.name, st.doc)
+	g.printf("type %s struct {\n", st.name)
+	if st.tpl != nil {
+		g.printf("TemplateID uint `fast:\"*\"`\n")
+	}
+	for _, f := range st.fields {
+		g.printf("%s %s `fast:%q`\n", f.goName, fieldType(f), fieldTag(f))
+	}
+	if st.tpl != nil {
+		g.printf("\nstate lockState `fast:\"-\"`\n")
+	}
+	g.printf("}\n\n")
+
+	if st.tpl != nil {
+		g.printRoot(st)
+	}
+
+	recv := "func (m *" + st.name + ") "
+	if st.tpl != nil {
+		g.printf("%ssetTemplateID(tid uint) { m.TemplateID = tid }\n\n", recv)
+		g.printf("%stemplateID() uint { return %d }\n\n", recv, st.tpl.ID)
+	} else {
+		g.printf("%ssetTemplateID(uint) {}\n\n", recv)
+		g.printf("%stemplateID() uint { return 0 }\n\n", recv)
+	}
+
+	g.printMethod(st, recv+"setValue(field *fast.Field)", kindValue, setValue)
+	g.printMethod(st, recv+"getValue(field *fast.Field)", kindValue, getValue)
+	g.printMethod(st, recv+"setLength(field *fast.Field)", kindSequence, setLength)
+	g.printMethod(st, recv+"getLength(field *fast.Field)", kindSequence, getLength)
+	g.printMethod(st, recv+"lock(field *fast.Field) segment", -1, lock)
+}
+
+// printRoot prints methods of fast.Receiver and fast.Sender.
+func (g *generator) printRoot(st *structType) {
+	recv := "func (m *" + st.name + ") "
+	g.printf("%sSetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }\n\n", recv)
+	g.printf("%sGetTemplateID() uint { return m.state.active(m).templateID() }\n\n", recv)
+	g.printf("%sSetValue(field *fast.Field) { m.state.active(m).setValue(field) }\n\n", recv)
+	g.printf("%sGetValue(field *fast.Field) { m.state.active(m).getValue(field) }\n\n", recv)
+	g.printf("%sSetLength(field *fast.Field) { m.state.active(m).setLength(field) }\n\n", recv)
+	g.printf("%sGetLength(field *fast.Field) { m.state.active(m).getLength(field) }\n\n", recv)
+	g.printf("%sLock(field *fast.Field) bool { return m.state.lock(m, field) }\n\n", recv)
+	g.printf("%sUnlock() { m.state.unlock() }\n\n", recv)
+}
+
+// printMethod prints method, which switches fields of kind by id, or by name if
+// field has no unique id. All kinds except values are switched if kind is negative.
+func (g *generator) printMethod(st *structType, signature string, kind fieldKind, body func(*field) string) {
+	var byID, byName []*field
+	ids := make(map[uint]bool)
+	for _, f := range st.fields {
+		if kind >= 0 && f.kind != kind || kind < 0 && f.kind == kindValue {
+			continue
+		}
+		if f.id == 0 || ids[f.id] {
+			byName = append(byName, f)
+			continue
+		}
+		ids[f.id] = true
+		byID = append(byID, f)
+	}
+
+	g.printf("%s {\n", signature)
+	if len(byID) > 0 {
+		g.printf("switch field.ID {\n")
+		for _, f := range byID {
+			g.printf("case %d:\n%s", f.id, body(f))
+		}
+		if len(byName) > 0 {
+			g.printf("default:\n")
+		}
+	}
+	if len(byName) > 0 {
+		g.printf("switch field.Name {\n")
+		for _, f := range byName {
+			g.printf("case %q:\n%s", f.name, body(f))
+		}
+		g.printf("}\n")
+	}
+	if len(byID) > 0 {
+		g.printf("}\n")
+	}
+	if kind < 0 {
+		g.printf("return nil\n")
+	}
+	g.printf("}\n\n")
+}
+
+func setValue(f *field) string {
+	switch {
+	case !f.optional || isSlice(f.goType):
+		return fmt.Sprintf("m.%s = field.Value.(%s)\n", f.goName, f.goType)
+	}
+	return fmt.Sprintf("v := field.Value.(%s)\nm.%s = &v\n", f.goType, f.goName)
+}
+
+func getValue(f *field) string {
+	switch {
+	case !f.optional:
+		return fmt.Sprintf("field.Value = m.%s\n", f.goName)
+	case isSlice(f.goType):
+		return fmt.Sprintf("if m.%[1]s != nil {\nfield.Value = m.%[1]s\n}\n", f.goName)
+	}
+	return fmt.Sprintf("if m.%[1]s != nil {\nfield.Value = *m.%[1]s\n}\n", f.goName)
+}
+
+func setLength(f *field) string {
+	return fmt.Sprintf("m.%s = make([]%s, field.Value.(int))\n", f.goName, f.goType)
+}
+
+func getLength(f *field) string {
+	return fmt.Sprintf("field.Value = len(m.%s)\n", f.goName)
+}
+
+func lock(f *field) string {
+	switch {
+	case f.kind == kindSequence:
+		return fmt.Sprintf("return &m.%s[field.Value.(int)]\n", f.goName)
+	case f.kind == kindTemplateRef:
+		return fmt.Sprintf("if m.%[1]s == nil {\nm.%[1]s = new(%[2]s)\n}\nreturn generic{m.%[1]s}\n", f.goName, f.goType)
+	case f.optional:
+		return fmt.Sprintf("if m.%[1]s == nil {\nm.%[1]s = new(%[2]s)\n}\nreturn m.%[1]s\n", f.goName, f.goType)
+	}
+	return fmt.Sprintf("return &m.%s\n", f.goName)
+}
+
+// fieldType returns Go type of struct field.
+func fieldType(f *field) string {
+	switch {
+	case f.kind == kindSequence:
+		return "[]" + f.goType
+	case f.kind == kindTemplateRef:
+		return "*" + f.goType
+	case f.optional && !isSlice(f.goType):
+		return "*" + f.goType
+	}
+	return f.goType
+}
+
+// isSlice returns true for value types, which are nil if value is absent.
+func isSlice(goType string) bool {
+	return strings.HasPrefix(goType, "[]")
+}
+
+// fieldTag returns tag of struct field for reflection.
+func fieldTag(f *field) string {
+	if f.id != 0 {
+		return strconv.FormatUint(uint64(f.id), 10)
+	}
+	return f.name
+}
+
+func valueType(typ fast.InstructionType) string {
+	switch typ {
+	case fast.TypeUint32:
+		return "uint32"
+	case fast.TypeInt32:
+		return "int32"
+	case fast.TypeUint64:
+		return "uint64"
+	case fast.TypeInt64:
+		return "int64"
+	case fast.TypeDecimal:
+		return "fast.Decimal"
+	case fast.TypeByteVector:
+		return "[]byte"
+	case fast.TypeBoolean:
+		return "bool"
+	case fast.TypeSet:
+		return "[]string"
+	case fast.TypeTimestamp, fast.TypeTime, fast.TypeDate:
+		return "time.Time"
+	}
+	return "string"
+}
+
+// goName returns exported Go identifier of name.
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('F')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "Field"
+	}
+	return b.String()
+}
+
+// uniqueName returns name with numeric suffix if the name is used.
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
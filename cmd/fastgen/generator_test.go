@@ -0,0 +1,91 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/co11ter/goFAST"
+)
+
+// TestGolden checks that generated package is up to date, run go generate ./... to update it.
+func TestGolden(t *testing.T) {
+	ftpl, err := os.Open("../../testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	tpls, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := generate(tpls, "generated", "test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := ioutil.ReadFile("../../internal/generated/messages.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, expect) {
+		t.Fatal("generated code differs from internal/generated/messages.go")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"MDEntryPx":   "MDEntryPx",
+		"md-entry_px": "MdEntryPx",
+		"1st":         "F1st",
+		"":            "Field",
+	}
+	for name, expect := range cases {
+		if current := goName(name); current != expect {
+			t.Fatalf("expected %s for %q, got %s", expect, name, current)
+		}
+	}
+}
+
+func TestGenerateExtension(t *testing.T) {
+	tpl := fast.NewTemplate(1, "Order").
+		Boolean("Active", 1).
+		Enum("Side", 2, []string{"Buy", "Sell"}).
+		Set("Flags", 3, []string{"A", "B"}, fast.Optional()).
+		Timestamp("Sent", 4, fast.Unit(time.Microsecond)).
+		BitGroup("Status", 5, fast.NewInstructions().Boolean("Open", 6)).
+		Template()
+	if err := fast.Compile(tpl); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := generate([]*fast.Template{tpl}, "generated", "order.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fields are compared without alignment
+	fields := strings.Join(strings.Fields(string(code)), " ")
+	for _, expect := range []string{
+		"\"time\"",
+		"Active bool `fast:\"1\"`",
+		"Side string `fast:\"2\"`",
+		"Flags []string `fast:\"3\"`",
+		"Sent time.Time `fast:\"4\"`",
+		"Status OrderStatus `fast:\"5\"`",
+		"Open bool `fast:\"6\"`",
+	} {
+		if !strings.Contains(fields, expect) {
+			t.Fatalf("expected %s in generated code:\n%s", expect, code)
+		}
+	}
+}
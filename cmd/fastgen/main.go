@@ -0,0 +1,71 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+/*
+Command fastgen generates Go types of FAST templates. The types implement
+fast.Receiver and fast.Sender, so messages are decoded and encoded without
+reflection.
+
+Usage:
+
+	fastgen -template templates.xml -package messages -output messages.go
+
+Each template is generated as struct with fields keyed by field id. Optional
+fields are pointers, decimals are fast.Decimal, groups are nested structs and
+sequences are slices of structs. Dynamic template reference is *fast.Message.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/co11ter/goFAST"
+)
+
+func main() {
+	var (
+		template = flag.String("template", "", "path of XML file of templates")
+		pkg      = flag.String("package", "main", "package name of generated code")
+		output   = flag.String("output", "", "path of generated file, standard output if empty")
+	)
+	flag.Parse()
+
+	if *template == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*template, *pkg, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "fastgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(template, pkg, output string) error {
+	file, err := os.Open(template)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tpls, err := fast.ParseXMLTemplate(file)
+	if err != nil {
+		return err
+	}
+
+	code, err := generate(tpls, pkg, filepath.Base(template))
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(output, code, 0644)
+}
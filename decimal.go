@@ -1,25 +1,211 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
 package fast
 
 import (
-	"github.com/shopspring/decimal"
 	"math"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	minExponent = -63
+	maxExponent = 63
+
+	maxExactMantissa = 1 << 53 // float64 represents integers exactly up to this value
+	maxExactExponent = 22      // float64 represents powers of ten exactly up to this value
 )
 
-// TODO int will be able overflow if exponent < 0 ??
-func newFloat(mantissa int64, exponent int32) (f float64) {
-	return float64(mantissa)/math.Pow10(int(exponent) * -1)
+var bigTen = big.NewInt(10)
+
+// Decimal is a FAST decimal value. It is equal to Mantissa * 10 ^ Exponent.
+type Decimal struct {
+	Mantissa int64
+	Exponent int32
 }
 
-func newMantExp(f float64) (int64, int32) {
+// NewDecimalFromFloat returns the decimal with the shortest representation of f.
+// It returns ErrR1 if f is not finite or can not be represented by FAST decimal.
+func NewDecimalFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, ErrR1
+	}
 	if f == 0 {
-		return 0,0
+		return Decimal{}, nil
 	}
-	d := decimal.NewFromFloat(f)
-	return d.Coefficient().Int64(), d.Exponent()
+	return NewDecimalFromDecimal(decimal.NewFromFloat(f))
 }
 
-func expDecimal(f float64) int32 {
-	return decimal.NewFromFloat(f).Exponent()
+// NewDecimalFromDecimal converts shopspring decimal to Decimal. It returns ErrR1
+// if d can not be represented by FAST decimal.
+func NewDecimalFromDecimal(d decimal.Decimal) (Decimal, error) {
+	return newDecimal(d.Coefficient(), d.Exponent())
+}
+
+// NewDecimalFromRat converts rational number to Decimal. It returns ErrR1 if r has
+// no exact decimal representation or it can not be represented by FAST decimal.
+func NewDecimalFromRat(r *big.Rat) (Decimal, error) {
+	num := new(big.Int).Set(r.Num())
+	denom := r.Denom()
+	mod := new(big.Int)
+
+	var exponent int32
+	for {
+		if mod.Mod(num, denom).Sign() == 0 {
+			return newDecimal(num.Quo(num, denom), exponent)
+		}
+		if exponent == minExponent {
+			return Decimal{}, ErrR1
+		}
+		num.Mul(num, bigTen)
+		exponent--
+	}
 }
 
+// ParseDecimal parses decimal from string like "-12.345" or "1.2e-3". It returns
+// ErrD11 if s has invalid syntax and ErrR1 if the value can not be represented
+// by FAST decimal.
+func ParseDecimal(s string) (Decimal, error) {
+	d, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return Decimal{}, ErrD11
+	}
+	return NewDecimalFromDecimal(d)
+}
+
+// newDecimal checks bounds of coefficient and exponent and tries to normalize them
+// if they are out of range.
+func newDecimal(coefficient *big.Int, exponent int32) (Decimal, error) {
+	coefficient = new(big.Int).Set(coefficient)
+	mod := new(big.Int)
+	quo := new(big.Int)
+
+	// remove trailing zeros while mantissa or exponent are too big
+	for (!coefficient.IsInt64() || exponent < minExponent) && coefficient.Sign() != 0 {
+		quo.QuoRem(coefficient, bigTen, mod)
+		if mod.Sign() != 0 {
+			return Decimal{}, ErrR1
+		}
+		coefficient.Set(quo)
+		exponent++
+	}
+
+	if coefficient.Sign() == 0 {
+		return Decimal{}, nil
+	}
+
+	for exponent > maxExponent {
+		coefficient.Mul(coefficient, bigTen)
+		if !coefficient.IsInt64() {
+			return Decimal{}, ErrR1
+		}
+		exponent--
+	}
+
+	return Decimal{Mantissa: coefficient.Int64(), Exponent: exponent}, nil
+}
 
+// Float64 returns the nearest float64 value for d.
+func (d Decimal) Float64() float64 {
+	if d.Mantissa < maxExactMantissa && d.Mantissa > -maxExactMantissa {
+		if d.Exponent >= 0 && d.Exponent <= maxExactExponent {
+			return float64(d.Mantissa) * math.Pow10(int(d.Exponent))
+		}
+		if d.Exponent < 0 && d.Exponent >= -maxExactExponent {
+			return float64(d.Mantissa) / math.Pow10(int(-d.Exponent))
+		}
+	}
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// ToDecimal converts d to shopspring decimal.
+func (d Decimal) ToDecimal() decimal.Decimal {
+	return decimal.New(d.Mantissa, d.Exponent)
+}
+
+// Rat returns d as rational number.
+func (d Decimal) Rat() *big.Rat {
+	pow := new(big.Int).Exp(bigTen, big.NewInt(int64(abs(d.Exponent))), nil)
+	if d.Exponent < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(d.Mantissa), pow)
+	}
+	return new(big.Rat).SetInt(pow.Mul(pow, big.NewInt(d.Mantissa)))
+}
+
+// String returns d in fixed point notation.
+func (d Decimal) String() string {
+	return d.ToDecimal().String()
+}
+
+// Equal returns true if d and other represent the same number.
+func (d Decimal) Equal(other Decimal) bool {
+	return d == other || d.ToDecimal().Equal(other.ToDecimal())
+}
+
+func (d Decimal) isValid() bool {
+	return d.Exponent >= minExponent && d.Exponent <= maxExponent
+}
+
+// toDecimal converts application value to Decimal.
+func toDecimal(value interface{}) (Decimal, error) {
+	switch value.(type) {
+	case Decimal:
+		return value.(Decimal), nil
+	case *Decimal:
+		return *value.(*Decimal), nil
+	case float64:
+		return NewDecimalFromFloat(value.(float64))
+	case float32:
+		return NewDecimalFromFloat(float64(value.(float32)))
+	case decimal.Decimal:
+		return NewDecimalFromDecimal(value.(decimal.Decimal))
+	case *decimal.Decimal:
+		return NewDecimalFromDecimal(*value.(*decimal.Decimal))
+	case big.Rat:
+		r := value.(big.Rat)
+		return NewDecimalFromRat(&r)
+	case *big.Rat:
+		return NewDecimalFromRat(value.(*big.Rat))
+	case string:
+		return ParseDecimal(value.(string))
+	case int64:
+		return Decimal{Mantissa: value.(int64)}, nil
+	case int32:
+		return Decimal{Mantissa: int64(value.(int32))}, nil
+	case int:
+		return Decimal{Mantissa: int64(value.(int))}, nil
+	}
+
+	// named and other numeric types
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		castTo(value, &f)
+		return NewDecimalFromFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var mantissa int64
+		if err := castTo(value, &mantissa); err != nil {
+			return Decimal{}, ErrR1
+		}
+		return Decimal{Mantissa: mantissa}, nil
+	}
+	return Decimal{}, ErrD1
+}
+
+func abs(value int32) int32 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func expDecimal(f float64) int32 {
+	return decimal.NewFromFloat(f).Exponent()
+}
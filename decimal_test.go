@@ -0,0 +1,77 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+	"github.com/shopspring/decimal"
+)
+
+func TestNewDecimalFromFloat(t *testing.T) {
+	cases := []struct {
+		value  float64
+		expect fast.Decimal
+	}{
+		{0, fast.Decimal{}},
+		{154.6, fast.Decimal{Mantissa: 1546, Exponent: -1}},
+		{0.0032, fast.Decimal{Mantissa: 32, Exponent: -4}},
+		{-5.15, fast.Decimal{Mantissa: -515, Exponent: -2}},
+		{1200, fast.Decimal{Mantissa: 12, Exponent: 2}},
+	}
+
+	for _, c := range cases {
+		got, err := fast.NewDecimalFromFloat(c.value)
+		if err != nil {
+			t.Fatal(c.value, err)
+		}
+		if got != c.expect {
+			t.Fatal("got:", got, "expect:", c.expect)
+		}
+		if got.Float64() != c.value {
+			t.Fatal("got:", got.Float64(), "expect:", c.value)
+		}
+	}
+}
+
+func TestDecimalConversion(t *testing.T) {
+	d := fast.Decimal{Mantissa: 123456789012345678, Exponent: -9}
+
+	if got := d.String(); got != "123456789.012345678" {
+		t.Fatal("string is not equal, got:", got)
+	}
+
+	if got, err := fast.ParseDecimal(d.String()); err != nil || got != d {
+		t.Fatal("parsed decimal is not equal, got:", got, err)
+	}
+
+	if got, err := fast.NewDecimalFromDecimal(d.ToDecimal()); err != nil || got != d {
+		t.Fatal("shopspring decimal is not equal, got:", got, err)
+	}
+
+	if got, err := fast.NewDecimalFromRat(d.Rat()); err != nil || !got.Equal(d) {
+		t.Fatal("rational is not equal, got:", got, err)
+	}
+}
+
+func TestDecimalErrR1(t *testing.T) {
+	if _, err := fast.NewDecimalFromRat(big.NewRat(1, 3)); err != fast.ErrR1 {
+		t.Fatal("expect ErrR1 for 1/3, got:", err)
+	}
+
+	if _, err := fast.NewDecimalFromDecimal(decimal.New(1, 64)); err != nil {
+		t.Fatal("expect normalized exponent, got:", err)
+	}
+
+	if _, err := fast.NewDecimalFromDecimal(decimal.New(3, -64)); err != fast.ErrR1 {
+		t.Fatal("expect ErrR1 for exponent -64, got:", err)
+	}
+
+	if _, err := fast.ParseDecimal("1.2.3"); err != fast.ErrD11 {
+		t.Fatal("expect ErrD11, got:", err)
+	}
+}
@@ -5,21 +5,30 @@
 package fast
 
 import (
+	"errors"
 	"io"
+	"math"
 	"sync"
 )
 
 // A Decoder reads and decodes FAST-encoded message from an io.Reader.
-// You may need buffered reader since decoder reads data byte by byte.
+// The decoder buffers data, so it may read more data from io.Reader than
+// the message requires.
 type Decoder struct {
 	repo map[uint]Template
 	storage storage
+	resets map[uint][]string // dictionaries reset by template id
 
 	tid uint // template id
 	pmc *pMapCollector
+	strict bool // report errors of overlong encoding and integer bounds
+	blockLength bool // each message is prefixed with block length preamble
 
 	reader *reader
+	window *reader // reader of DecodeBytes data
 	msg Receiver
+	receiver receiverGuard // recovers panics of Receiver methods
+	plans planCache // plans of reflection by message type
 
 	logger *readerLog
 	mu sync.Mutex
@@ -29,12 +38,16 @@ type Decoder struct {
 func NewDecoder(reader io.Reader, tmps ...*Template) *Decoder {
 	decoder := &Decoder{
 		repo: make(map[uint]Template),
+		resets: make(map[uint][]string),
 		storage: newStorage(),
 		reader: newReader(reader),
 		pmc: newPMapCollector(),
+		plans: newPlanCache(),
 	}
 	for _, t := range tmps {
-		decoder.repo[t.ID] = t.clone()
+		tpl := t.clone()
+		decoder.plans.number(tpl.Instructions)
+		decoder.repo[t.ID] = tpl
 	}
 	return decoder
 }
@@ -46,19 +59,87 @@ func (d *Decoder) Reset() {
 	d.storage = newStorage()
 }
 
+// ResetDictionary resets all values of dictionary by name. The name is "global"
+// or user defined name of dictionary.
+func (d *Decoder) ResetDictionary(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.storage.reset(name)
+}
+
+// ResetTemplate resets template dictionary and all values used by instructions
+// of template. It returns ErrD9 if template is unknown.
+func (d *Decoder) ResetTemplate(id uint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tpl, ok := d.repo[id]
+	if !ok {
+		return ErrD9
+	}
+	d.storage.resetTemplate(&tpl)
+	return nil
+}
+
+// ResetKey resets single value of dictionary by key.
+func (d *Decoder) ResetKey(dictionary, key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.storage.resetKey(dictionary, key)
+}
+
+// SetResetTemplate marks template as reset template. The dictionaries are reset
+// each time the message of template is decoded, all dictionaries are reset
+// if names are not specified.
+func (d *Decoder) SetResetTemplate(id uint, dictionaries ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resets[id] = dictionaries
+}
+
+// SetStrict sets validation mode. In strict mode decoder reports integers out of
+// bounds of field type (D2), overlong integers (R6), overlong presence maps (R7)
+// and presence maps with more bits than required (R8). Lenient mode is default.
+func (d *Decoder) SetStrict(strict bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.strict = strict
+	d.reader.strict = strict
+	if d.window != nil {
+		d.window.strict = strict
+	}
+}
+
+// SetBlockLength sets mode of block length preamble. If enabled, each message is
+// expected to be prefixed with stop bit encoded uInt32 count of bytes of the message.
+// Decoder checks the length against consumed bytes and skips messages of unknown
+// templates instead of ErrD9. Values of skipped message are not applied to
+// dictionaries, so the next messages may depend on them.
+func (d *Decoder) SetBlockLength(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.blockLength = enabled
+}
+
 // SetLog sets writer for logging
 func (d *Decoder) SetLog(writer io.Writer) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	if writer != nil {
-		d.logger = wrapReaderLog(d.reader.reader, writer)
-		d.reader = newReader(d.logger)
+		d.logger = wrapReaderLog(writer)
+		d.reader.log = writer
+		if d.window != nil {
+			d.window.log = writer
+		}
 		return
 	}
 
 	if d.logger != nil {
-		d.reader = newReader(d.logger.Reader)
+		d.reader.log = nil
+		if d.window != nil {
+			d.window.log = nil
+		}
 		d.logger = nil
 	}
 }
@@ -66,11 +147,109 @@ func (d *Decoder) SetLog(writer io.Writer) {
 // Decode reads the next FAST-encoded message from reader and stores it
 // in the value pointed to by msg. If an encountered data implements the
 // Receiver interface and is not a nil pointer, Decode will use methods
-// of Receiver for set decoded data.
+// of Receiver for set decoded data. Panic of Receiver is returned as Error caused
+// by ErrPanic.
 func (d *Decoder) Decode(msg interface{}) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	return d.decode(msg)
+}
+
+// Read reads raw data, which is not decoded yet, e.g. preamble of the next message.
+// The decoder buffers data of io.Reader, so the data must be read by Read instead
+// of the io.Reader.
+func (d *Decoder) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reader.read(p)
+}
+
+// DecodeBytes decodes the FAST-encoded message from data and stores it in
+// the value pointed to by msg. It returns count of consumed bytes, so the next
+// message starts at data[n:]. The io.Reader of decoder is not used. Offset of
+// returned Error is relative to the start of data.
+func (d *Decoder) DecodeBytes(data []byte, msg interface{}) (n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.window == nil {
+		d.window = newReader(nil)
+		d.window.strict = d.strict
+		d.window.log = d.reader.log
+	}
+	d.window.reset(data)
+
+	current := d.reader
+	d.reader = d.window
+	err = d.decode(msg)
+	d.reader = current
+
+	return d.window.pos, err
+}
+
+func (d *Decoder) decode(msg interface{}) error {
+	if !d.blockLength {
+		return d.decodeMessage(msg)
+	}
+
+	for {
+		start := d.reader.offset
+		if d.logger != nil {
+			d.logger.prefix = "\n"
+			d.logger.Log("block length decoding: ")
+		}
+
+		tmp, err := d.reader.ReadUint(false)
+		if err == io.EOF && d.reader.offset == start {
+			return err // there is no more messages
+		}
+		if err != nil {
+			return templateError(err, nil, d.reader.offset)
+		}
+		if *tmp == 0 {
+			return &Error{Operator: OperatorNone, Offset: start, Err: ErrD12}
+		}
+		if d.strict && *tmp > math.MaxUint32 {
+			return &Error{Operator: OperatorNone, Offset: start, Err: ErrD2}
+		}
+
+		length := int64(*tmp)
+		if d.logger != nil {
+			d.logger.Log("  block length = ", length)
+		}
+
+		start = d.reader.offset
+		err = d.decodeMessage(msg)
+		if err == io.EOF {
+			return templateError(err, nil, d.reader.offset)
+		}
+
+		consumed := d.reader.offset - start
+		if consumed < length && (err == nil || errors.Is(err, ErrD9)) {
+			if d.logger != nil {
+				d.logger.Log("skip: ")
+			}
+			if skipErr := d.reader.skip(length - consumed); skipErr != nil {
+				return templateError(skipErr, nil, d.reader.offset)
+			}
+		}
+
+		switch {
+		case errors.Is(err, ErrD9) && consumed <= length:
+			continue // message of unknown template is skipped
+		case err != nil:
+			return err
+		case consumed != length:
+			tpl := d.repo[d.tid]
+			return templateError(ErrBlockLength, &tpl, start)
+		}
+		return nil
+	}
+}
+
+// decodeMessage decodes message without block length preamble.
+func (d *Decoder) decodeMessage(msg interface{}) error {
 	d.tid = 0
 	d.pmc.reset()
 
@@ -80,9 +259,13 @@ func (d *Decoder) Decode(msg interface{}) error {
 		d.logger.Log("pmap decoding: ")
 	}
 
+	start := d.reader.offset
 	err := d.visitPMap()
+	if err == io.EOF && d.reader.offset == start {
+		return err // there is no more messages
+	}
 	if err != nil {
-		return err
+		return templateError(err, nil, d.reader.offset)
 	}
 
 	if d.logger != nil {
@@ -91,7 +274,7 @@ func (d *Decoder) Decode(msg interface{}) error {
 
 	d.tid, err = d.visitTemplateID()
 	if err != nil {
-		return err
+		return templateError(err, nil, d.reader.offset)
 	}
 
 	if d.logger != nil {
@@ -100,14 +283,47 @@ func (d *Decoder) Decode(msg interface{}) error {
 
 	tpl, ok := d.repo[d.tid]
 	if !ok {
-		return ErrD9
+		return &Error{TemplateID: d.tid, Operator: OperatorNone, Offset: d.reader.offset, Err: ErrD9}
 	}
 
-	if d.msg, ok = msg.(Receiver); !ok {
-		d.msg = makeMsg(msg)
+	if d.receiver.receiver, ok = msg.(Receiver); ok {
+		d.receiver.err = nil
+		d.msg = &d.receiver
+	} else if d.msg, err = makeMsg(msg, &d.plans); err != nil {
+		return templateError(err, &tpl, d.reader.offset)
+	}
+	if m, ok := msg.(*Message); ok {
+		m.reset()
 	}
 	d.msg.SetTemplateID(d.tid)
-	return d.decodeSegment(tpl.Instructions)
+	if err = d.msgErr(); err != nil {
+		return templateError(err, &tpl, d.reader.offset)
+	}
+	err = d.decodeSegment(tpl.Instructions)
+	if err == nil {
+		err = d.msgErr() // error of the last method, e.g. Unlock
+	}
+	if err != nil {
+		return templateError(err, &tpl, d.reader.offset)
+	}
+
+	err = d.checkPMap()
+	if err != nil {
+		return templateError(err, &tpl, d.reader.offset)
+	}
+
+	if dictionaries, ok := d.resets[d.tid]; ok {
+		d.storage.resetDictionaries(dictionaries)
+	}
+	return nil
+}
+
+// checkPMap returns ErrR8 in strict mode if active presence map has unused bits set.
+func (d *Decoder) checkPMap() error {
+	if d.strict && d.pmc.active().hasUnusedBits() {
+		return ErrR8
+	}
+	return nil
 }
 
 func (d *Decoder) visitPMap() error {
@@ -120,15 +336,23 @@ func (d *Decoder) visitPMap() error {
 	return nil
 }
 
+// visitTemplateID decodes template identifier. It is encoded with copy operator
+// in global dictionary, so the previous identifier is used if pmap bit is not set.
 func (d *Decoder) visitTemplateID() (uint, error) {
 	if d.pmc.active().IsNextBitSet() {
 		tmp, err := d.reader.ReadUint(false)
 		if err != nil {
 			return 0, err
 		}
+		d.storage.saveTemplateID(uint(*tmp))
 		return uint(*tmp), nil
 	}
-	return 0, nil
+
+	previous, ok := d.storage.lookupTemplateID()
+	if !ok {
+		return 0, ErrD5
+	}
+	return previous, nil
 }
 
 func (d *Decoder) decodeGroup(instruction *Instruction) error {
@@ -146,6 +370,7 @@ func (d *Decoder) decodeGroup(instruction *Instruction) error {
 	parent := acquireField()
 	parent.ID = instruction.ID
 	parent.Name = instruction.Name
+	parent.instruction = instruction
 
 	if instruction.pMapSize > 0 {
 		if d.logger != nil {
@@ -161,6 +386,9 @@ func (d *Decoder) decodeGroup(instruction *Instruction) error {
 	}
 
 	locked := d.msg.Lock(parent)
+	if err := d.msgErr(); err != nil {
+		return err
+	}
 	err := d.decodeSegment(instruction.Instructions)
 	if err != nil {
 		return err
@@ -171,6 +399,9 @@ func (d *Decoder) decodeGroup(instruction *Instruction) error {
 	}
 
 	if instruction.pMapSize > 0 {
+		if err = d.checkPMap(); err != nil {
+			return err
+		}
 		d.pmc.restore()
 	}
 
@@ -178,33 +409,150 @@ func (d *Decoder) decodeGroup(instruction *Instruction) error {
 	return nil
 }
 
+// decodeBitGroup decodes boolean fields of group from bits of unsigned integer.
+func (d *Decoder) decodeBitGroup(instruction *Instruction) error {
+	value, err := instruction.extract(d.reader, d.storage, d.pmc.active())
+	if err != nil || value == nil {
+		return err
+	}
+	bits := value.(uint64)
+
+	if d.logger != nil {
+		d.logger.Log("bit group = ", bits)
+	}
+
+	parent := acquireField()
+	parent.ID = instruction.ID
+	parent.Name = instruction.Name
+	parent.instruction = instruction
+
+	if d.msg.Lock(parent) {
+		for index, inner := range instruction.Instructions {
+			field := acquireField()
+			field.ID = inner.ID
+			field.Name = inner.Name
+			field.instruction = inner
+			field.Value = bits&(1<<uint(index)) != 0
+			err = d.setValue(field)
+			releaseField(field)
+			if err != nil {
+				err = wrapError(err, pathName(inner), inner.Operator, d.reader.offset)
+				break
+			}
+		}
+		d.msg.Unlock()
+	}
+
+	releaseField(parent)
+	if err == nil {
+		err = d.msgErr()
+	}
+	return err
+}
+
+// setValue sets value of field to message. It returns error of conversion to type
+// of struct field, if message is decoded by reflection.
+func (d *Decoder) setValue(field *Field) error {
+	d.msg.SetValue(field)
+	return d.msgErr()
+}
+
+// msgErr returns error of reflection or panic of Receiver method.
+func (d *Decoder) msgErr() error {
+	return d.msg.(flusher).flushErr()
+}
+
+// decodeTemplateRef decodes nested message of dynamic template reference.
+func (d *Decoder) decodeTemplateRef() error {
+	if d.logger != nil {
+		d.logger.Log("template reference start: ")
+		d.logger.Log("pmap decoding: ")
+	}
+
+	err := d.visitPMap()
+	if err != nil {
+		return err
+	}
+
+	if d.logger != nil {
+		d.logger.Log("  pmap = ", *d.pmc.active(), "\ntemplate decoding: ")
+	}
+
+	tid, err := d.visitTemplateID()
+	if err != nil {
+		return err
+	}
+
+	if d.logger != nil {
+		d.logger.Log("  template = ", tid)
+	}
+
+	tpl, ok := d.repo[tid]
+	if !ok {
+		return ErrD9
+	}
+
+	parent := acquireField()
+	defer releaseField(parent)
+	parent.Name = tagTemplateRef
+	parent.Value = tid
+
+	locked := d.msg.Lock(parent)
+	if locked {
+		d.msg.SetTemplateID(tid)
+	}
+	if err = d.msgErr(); err != nil {
+		return err
+	}
+
+	err = d.decodeSegment(tpl.Instructions)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		d.msg.Unlock()
+	}
+
+	if err = d.checkPMap(); err != nil {
+		return err
+	}
+	d.pmc.restore()
+	return nil
+}
+
 func (d *Decoder) decodeSequence(instruction *Instruction) error {
 	if d.logger != nil {
 		d.logger.Log("sequence start: ")
 	}
 
-	tmp, err := instruction.Instructions[0].extract(d.reader, d.storage, d.pmc.active())
+	length := instruction.Instructions[0]
+	tmp, err := length.extract(d.reader, d.storage, d.pmc.active())
 	if err != nil {
-		return err
+		return wrapError(err, pathName(length), length.Operator, d.reader.offset)
 	}
 
 	if tmp == nil {
 		return nil
 	}
 
-	length := int(tmp.(uint32))
+	count := int(tmp.(uint32))
 	if d.logger != nil {
-		d.logger.Log("  length = ", length)
+		d.logger.Log("  length = ", count)
 	}
 
 	parent := acquireField()
 	parent.ID = instruction.ID
 	parent.Name = instruction.Name
-	parent.Value = length
+	parent.instruction = instruction
+	parent.Value = count
 
 	d.msg.SetLength(parent)
+	if err = d.msgErr(); err != nil {
+		return err
+	}
 
-	for i:=0; i<length; i++ {
+	for i:=0; i<count; i++ {
 		parent.Value = i
 		if d.logger != nil {
 			d.logger.Log("sequence elem[", i, "] start: ")
@@ -216,7 +564,7 @@ func (d *Decoder) decodeSequence(instruction *Instruction) error {
 			}
 			err = d.visitPMap()
 			if err != nil {
-				return err
+				return wrapError(err, elemName(i), OperatorNone, d.reader.offset)
 			}
 			if d.logger != nil {
 				d.logger.Log("  pmap = ", *d.pmc.active())
@@ -224,9 +572,11 @@ func (d *Decoder) decodeSequence(instruction *Instruction) error {
 		}
 
 		locked := d.msg.Lock(parent)
-		err = d.decodeSegment(instruction.Instructions[1:])
+		if err = d.msgErr(); err == nil {
+			err = d.decodeSegment(instruction.Instructions[1:])
+		}
 		if err != nil {
-			return err
+			return wrapError(err, elemName(i), OperatorNone, d.reader.offset)
 		}
 
 		if locked {
@@ -234,6 +584,9 @@ func (d *Decoder) decodeSequence(instruction *Instruction) error {
 		}
 
 		if instruction.pMapSize > 0 {
+			if err = d.checkPMap(); err != nil {
+				return wrapError(err, elemName(i), OperatorNone, d.reader.offset)
+			}
 			d.pmc.restore()
 		}
 	}
@@ -255,6 +608,10 @@ func (d *Decoder) decodeSegment(instructions []*Instruction) error {
 			err = d.decodeSequence(instruction)
 		case TypeGroup:
 			err = d.decodeGroup(instruction)
+		case TypeTemplateRef:
+			err = d.decodeTemplateRef()
+		case TypeBitGroup:
+			err = d.decodeBitGroup(instruction)
 		default:
 			if d.logger != nil {
 				d.logger.Log("decoding: ", instruction.Name)
@@ -265,9 +622,11 @@ func (d *Decoder) decodeSegment(instructions []*Instruction) error {
 			field := acquireField()
 			field.ID = instruction.ID
 			field.Name = instruction.Name
+			field.instruction = instruction
 			field.Value, err = instruction.extract(d.reader, d.storage, d.pmc.active())
 			if err != nil {
-				return err
+				releaseField(field)
+				break
 			}
 
 			if d.logger != nil {
@@ -275,13 +634,13 @@ func (d *Decoder) decodeSegment(instructions []*Instruction) error {
 			}
 
 			if field.Value != nil {
-				d.msg.SetValue(field)
+				err = d.setValue(field)
 			}
 			releaseField(field)
 		}
 
 		if err != nil {
-			return err
+			return wrapError(err, pathName(instruction), instruction.Operator, d.reader.offset)
 		}
 	}
 
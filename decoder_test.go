@@ -6,12 +6,15 @@ package fast_test
 
 import (
 	"bytes"
+	"errors"
 	"github.com/co11ter/goFAST"
 	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
+	"testing/iotest"
 )
 
 var (
@@ -57,6 +60,18 @@ func TestDecimalDecode(t *testing.T) {
 	decode(decimalData2, &msg2, &decimalMessage2, t)
 }
 
+func TestDecimalValueDecode(t *testing.T) {
+	decoder.Reset()
+
+	var msg decimalValueType
+	decode(decimalData1, &msg, &decimalValueMessage1, t)
+
+	decoder.Reset()
+
+	var named decimalNamedType
+	decode(decimalData1, &named, &decimalNamedMessage1, t)
+}
+
 func TestSequenceDecode(t *testing.T) {
 	var msg sequenceType
 	decode(sequenceData1, &msg, &sequenceMessage1, t)
@@ -70,11 +85,17 @@ func TestByteVectorDecode(t *testing.T) {
 func TestStringDecode(t *testing.T) {
 	var msg stringType
 	decode(stringData1, &msg, &stringMessage1, t)
+
+	msg = stringType{}
+	decode(stringData2, &msg, &stringMessage2, t)
 }
 
 func TestIntegerDecode(t *testing.T) {
 	var msg integerType
 	decode(integerData1, &msg, &integerMessage1, t)
+
+	msg = integerType{}
+	decode(integerData2, &msg, &integerMessage2, t)
 }
 
 func TestGroupDecode(t *testing.T) {
@@ -82,6 +103,362 @@ func TestGroupDecode(t *testing.T) {
 	decode(groupData1, &msg, &groupMessage1, t)
 }
 
+func TestTailDecode(t *testing.T) {
+	var msg1, msg2, msg3, msg4 tailType
+	decode(tailData1, &msg1, &tailMessage1, t)
+	decode(tailData2, &msg2, &tailMessage2, t)
+	decode(tailData3, &msg3, &tailMessage3, t)
+	decode(tailData4, &msg4, &tailMessage4, t) // initial value is the base after empty previous value
+}
+
+func TestDeltaDecode(t *testing.T) {
+	var msg1, msg2, msg3 deltaType
+	decode(deltaData1, &msg1, &deltaMessage1, t)
+	decode(deltaData2, &msg2, &deltaMessage2, t)
+	decode(deltaData3, &msg3, &deltaMessage3, t)
+}
+
+func TestStaticReferenceDecode(t *testing.T) {
+	var msg staticReferenceType
+	decode(staticReferenceData1, &msg, &staticReferenceMessage1, t)
+}
+
+func TestDynamicReferenceDecode(t *testing.T) {
+	var msg dynamicReferenceType
+	decode(dynamicReferenceData1, &msg, &dynamicReferenceMessage1, t)
+}
+
+func TestDictionaryDecode(t *testing.T) {
+	decoder.Reset()
+
+	var msg1, msg3 dictionaryAType
+	var msg2 dictionaryBType
+	decode(dictionaryData1, &msg1, &dictionaryMessage1, t)
+	decode(dictionaryData2, &msg2, &dictionaryMessage2, t)
+	decode(dictionaryData3, &msg3, &dictionaryMessage3, t)
+}
+
+func TestResetDecode(t *testing.T) {
+	decoder.Reset()
+
+	var msg1, msg2, msg3, msg4, msg5, msg6 dictionaryAType
+	decode(dictionaryData1, &msg1, &dictionaryMessage1, t)
+
+	if err := decoder.ResetTemplate(12); err != nil {
+		t.Fatal(err)
+	}
+	decode(dictionaryData5, &msg2, &dictionaryMessage1, t)
+
+	var reset resetType
+	decoder.SetResetTemplate(14)
+	decode(resetData1, &reset, &resetMessage1, t)
+	decode(dictionaryData1, &msg3, &dictionaryMessage1, t)
+
+	decoder.ResetKey("global", "GlobalKey")
+	decode(dictionaryData6, &msg4, &dictionaryMessage3, t)
+
+	decoder.ResetDictionary("global")
+	decode(dictionaryData4, &msg5, &dictionaryMessage3, t)
+	decode(dictionaryData7, &msg6, &dictionaryMessage3, t)
+
+	if err := decoder.ResetTemplate(100); err != fast.ErrD9 {
+		t.Fatal("expected ErrD9, got", err)
+	}
+}
+
+func TestTemplateIDDecode(t *testing.T) {
+	decoder.Reset()
+
+	reader.Write(dictionaryData7)
+	var msg dictionaryAType
+	if err := decoder.Decode(&msg); !errors.Is(err, fast.ErrD5) {
+		t.Fatal("expected ErrD5, got", err)
+	}
+	reader.Reset()
+
+	var msg1, msg2 dictionaryAType
+	decode(dictionaryData1, &msg1, &dictionaryMessage1, t)
+	decode(dictionaryData7, &msg2, &dictionaryMessage3, t)
+}
+
+func TestStrictDecode(t *testing.T) {
+	strict := newDecoder(nil, t)
+	strict.SetStrict(true)
+
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{0xc0, 0x89, 0x87}, nil},
+		{[]byte{0xc0, 0x89, 0x00, 0x87}, fast.ErrR6},
+		{[]byte{0xc0, 0x89, 0x10, 0x00, 0x00, 0x00, 0x80}, fast.ErrD2},
+		{[]byte{0x40, 0x80, 0x89, 0x87}, fast.ErrR7},
+		{[]byte{0xe0, 0x89, 0x87}, fast.ErrR8},
+	}
+
+	for i, c := range cases {
+		var msg headerType
+		if _, err := strict.DecodeBytes(c.data, &msg); !errors.Is(err, c.err) {
+			t.Fatal("case", i, "expected", c.err, "got", err)
+		}
+	}
+
+	// lenient mode accepts overlong encoding
+	var msg headerType
+	decode([]byte{0xc0, 0x89, 0x00, 0x87}, &msg, &headerType{TemplateID: 9, HeaderSeqNum: 7}, t)
+}
+
+func TestDecodeBytes(t *testing.T) {
+	data := append(append([]byte{}, tailData1...), tailData2...)
+	data = append(data, tailData3...)
+
+	d := newDecoder(nil, t)
+	for _, expect := range []interface{}{&tailMessage1, &tailMessage2, &tailMessage3} {
+		var msg tailType
+		n, err := d.DecodeBytes(data, &msg)
+		if err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if !reflect.DeepEqual(&msg, expect) {
+			t.Fatal("messages is not equal, got: ", msg, ", expect: ", expect)
+		}
+		data = data[n:]
+	}
+
+	if len(data) != 0 {
+		t.Fatal("data is not consumed:", data)
+	}
+
+	var msg tailType
+	if n, err := d.DecodeBytes(data, &msg); n != 0 || err != io.EOF {
+		t.Fatal("expected io.EOF, got", n, err)
+	}
+}
+
+func TestBlockLengthDecode(t *testing.T) {
+	unknown := []byte{0x84, 0xc0, 0xe4, 0x01, 0x82} // message of unknown template 100
+	data := append(append([]byte{0x8d}, decimalData1...), unknown...)
+	data = append(append(data, 0x8d), decimalData1...)
+
+	d := newDecoder(iotest.OneByteReader(bytes.NewReader(data)), t)
+	d.SetBlockLength(true)
+	for i := 0; i < 2; i++ {
+		d.Reset() // the data of each message are encoded with empty dictionaries
+		var msg decimalType
+		if err := d.Decode(&msg); err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if !reflect.DeepEqual(msg, decimalMessage1) {
+			t.Fatal("messages is not equal, got: ", msg, ", expect: ", decimalMessage1)
+		}
+	}
+
+	var msg decimalType
+	if err := d.Decode(&msg); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{data: append([]byte{0x80}, decimalData1...), err: fast.ErrD12},
+		{data: append(append([]byte{0x8e}, decimalData1...), 0x80), err: fast.ErrBlockLength},
+		{data: append([]byte{0x8c}, decimalData1...), err: fast.ErrBlockLength},
+		{data: []byte{0x8d, 0xf8}, err: io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		d := newDecoder(nil, t)
+		d.SetBlockLength(true)
+		if _, err := d.DecodeBytes(c.data, &msg); !errors.Is(err, c.err) {
+			t.Fatal("expected", c.err, "got", err)
+		}
+	}
+}
+
+func TestDecodeOneByteReader(t *testing.T) {
+	data := append(append([]byte{}, stringData1...), byteVectorData1...)
+	d := newDecoder(iotest.OneByteReader(bytes.NewReader(data)), t)
+
+	var msg1 stringType
+	if err := d.Decode(&msg1); err != nil {
+		t.Fatal("can not decode", err)
+	}
+	if !reflect.DeepEqual(msg1, stringMessage1) {
+		t.Fatal("messages is not equal, got: ", msg1, ", expect: ", stringMessage1)
+	}
+
+	var msg2 byteVectorType
+	if err := d.Decode(&msg2); err != nil {
+		t.Fatal("can not decode", err)
+	}
+	if !reflect.DeepEqual(msg2, byteVectorMessage1) {
+		t.Fatal("messages is not equal, got: ", msg2, ", expect: ", byteVectorMessage1)
+	}
+}
+
+func TestByteVectorLengthDecode(t *testing.T) {
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		// length is over uInt32
+		{data: []byte{0xc0, 0x83, 0x20, 0x00, 0x00, 0x00, 0x00, 0x80}, err: fast.ErrD2},
+		// length is not covered by data of reader
+		{data: []byte{0xc0, 0x83, 0x07, 0x7f, 0x7f, 0x7f, 0xff, 0x01, 0x02}, err: io.ErrUnexpectedEOF},
+	}
+
+	for i, c := range cases {
+		var msg byteVectorType
+		err := newDecoder(bytes.NewReader(c.data), t).Decode(&msg)
+		if !errors.Is(err, c.err) {
+			t.Fatal("case", i, "expected", c.err, "got", err)
+		}
+	}
+}
+
+func TestErrorDecode(t *testing.T) {
+	buf := bytes.NewBuffer(sequenceData1[:7])
+	var msg sequenceType
+	err := newDecoder(buf, t).Decode(&msg)
+
+	expect := &fast.Error{
+		TemplateID:   2,
+		TemplateName: "Sequence",
+		Path:         "OuterSequence[0].InnerSequence[1].InnerTestData",
+		Operator:     fast.OperatorNone,
+		Offset:       7,
+		Err:          io.ErrUnexpectedEOF,
+	}
+	if !reflect.DeepEqual(err, expect) {
+		t.Fatal("expected", expect, "got", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("error does not wrap cause")
+	}
+
+	if err = newDecoder(&bytes.Buffer{}, t).Decode(&msg); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+}
+
+// panicReceiver panics on SetValue.
+type panicReceiver struct{}
+
+func (r *panicReceiver) SetTemplateID(uint) {}
+func (r *panicReceiver) SetValue(*fast.Field) { panic("no value") }
+func (r *panicReceiver) SetLength(*fast.Field) {}
+func (r *panicReceiver) Lock(*fast.Field) bool { return false }
+func (r *panicReceiver) Unlock() {}
+
+type intSliceSequenceType struct {
+	TemplateID    uint `fast:"*"`
+	OuterSequence []int
+}
+
+type boolTemplateIDType struct {
+	TemplateID bool `fast:"*"`
+}
+
+func TestMessageErrorDecode(t *testing.T) {
+	cases := []struct {
+		msg interface{}
+		err error
+	}{
+		{sequenceType{}, fast.ErrMessageType},
+		{&intSequenceType{}, fast.ErrMessageType},
+		{&intSliceSequenceType{}, fast.ErrMessageType},
+		{&boolTemplateIDType{}, fast.ErrD1},
+		{&panicReceiver{}, fast.ErrPanic},
+	}
+	for _, c := range cases {
+		err := newDecoder(bytes.NewReader(sequenceData1), t).Decode(c.msg)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%T: expected %v, got %v", c.msg, c.err, err)
+		}
+	}
+}
+
+func TestSharedKeyDecode(t *testing.T) {
+	d := fast.NewDecoder(nil, sharedKeyTemplates(t)...)
+
+	var msg1 sharedUintType
+	if _, err := d.DecodeBytes([]byte{0xe0, 0x81, 0x85}, &msg1); err != nil {
+		t.Fatal("can not decode", err)
+	}
+
+	// previous value of string field is uInt32
+	var msg2 sharedStringType
+	if _, err := d.DecodeBytes([]byte{0xc0, 0x82}, &msg2); !errors.Is(err, fast.ErrD4) {
+		t.Fatal("expected", fast.ErrD4, "got", err)
+	}
+}
+
+func TestTemplateIDFieldDecode(t *testing.T) {
+	d := fast.NewDecoder(nil, templateIDFieldTemplates(t)...)
+
+	expect := templateIDFieldType{TemplateID: 1, Field: 5}
+	for _, data := range [][]byte{{0xe0, 0x81, 0x85}, {0x80}} {
+		var msg templateIDFieldType
+		if _, err := d.DecodeBytes(data, &msg); err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if msg != expect {
+			t.Fatal("messages is not equal, got: ", msg, ", expect: ", expect)
+		}
+	}
+}
+
+func TestConcurrentDecode(t *testing.T) {
+	type message struct {
+		TemplateID    uint `fast:"*"`
+		TestData      uint32
+		OuterSequence []*struct {
+			OuterTestData *uint32
+			InnerSequence *[]struct {
+				InnerTestData uint32
+			}
+		}
+		NextOuterSequence []struct {
+			NextOuterTestData uint32
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var msg message
+			errs <- newDecoder(bytes.NewReader(sequenceData1), t).Decode(&msg)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newDecoder returns decoder of test templates.
+func newDecoder(r io.Reader, t testing.TB) *fast.Decoder {
+	ftpl, err := os.Open("testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	tpls, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fast.NewDecoder(r, tpls...)
+}
+
 // write profile command: go test -bench=BenchmarkDecoder_DecodeReflection -cpuprofile=cpu.out -memprofile=mem.out
 // convert to cpuprof.pdf command: go tool pprof -pdf -output=cpuprof.pdf goFAST.test cpu.out
 // convert to memprof.pdf command: go tool pprof -pdf -output=memprof.pdf goFAST.test mem.out
@@ -98,23 +475,56 @@ func BenchmarkDecoder_DecodeReceiver(b *testing.B) {
 	benchDecode(b, &msg)
 }
 
-func benchDecode(b *testing.B, msg interface{}) {
+func BenchmarkDecoder_DecodeBytesReflection(b *testing.B) {
+	var msg benchmarkMessage
+	benchDecodeBytes(b, &msg)
+}
+
+func BenchmarkDecoder_DecodeBytesReceiver(b *testing.B) {
+	var msg benchmarkReceiver
+	benchDecodeBytes(b, &msg)
+}
+
+// benchData returns messages of testdata/data.dat. Each message is preceded by
+// 4 bytes of sequence number.
+func benchData(b *testing.B) []byte {
 	file, err := os.Open("testdata/data.dat")
 	if err != nil {
 		b.Fatal(err)
 	}
+	defer file.Close()
 
-	data, _ := ioutil.ReadAll(file)
-	file.Close()
-	reader.Write(data)
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func benchDecode(b *testing.B, msg interface{}) {
+	data := benchData(b)
+
+	// decoder reads stream of messages, so sequence numbers are removed
+	var stream []byte
+	d := newDecoder(nil, b)
+	for pos := 0; pos < len(data); {
+		n, err := d.DecodeBytes(data[pos+4:], msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		stream = append(stream, data[pos+4:pos+4+n]...)
+		pos += 4 + n
+	}
+
+	src := bytes.NewReader(stream)
+	d = newDecoder(src, b)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		reader.Next(4) // skip sequence data
-		err = decoder.Decode(msg)
+		err := d.Decode(msg)
 		if err == io.EOF {
 			b.StopTimer()
-			reader.Write(data)
+			src.Reset(stream)
 			b.StartTimer()
 			continue
 		}
@@ -123,4 +533,24 @@ func benchDecode(b *testing.B, msg interface{}) {
 		}
 	}
 	b.ReportAllocs()
-}
\ No newline at end of file
+}
+
+func benchDecodeBytes(b *testing.B, msg interface{}) {
+	data := benchData(b)
+	d := newDecoder(nil, b)
+
+	pos := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if pos >= len(data) {
+			pos = 0
+		}
+		pos += 4 // skip sequence number
+		n, err := d.DecodeBytes(data[pos:], msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pos += n
+	}
+	b.ReportAllocs()
+}
@@ -5,7 +5,6 @@
 package fast
 
 import (
-	"bytes"
 	"io"
 	"sync"
 )
@@ -14,21 +13,34 @@ import (
 type Encoder struct {
 	repo map[uint]Template
 	storage storage
+	resets map[uint][]string // dictionaries reset by template id
 
 	tid uint // template id
+	forceTID bool // write template id in each message
+	blockLength bool // prefix each message with block length preamble
 	pmc *pMapCollector
 
-	writers []*writer
-	writerIndex int // index for current writer
+	writer *writer
+	pmaps []*pMap // reusable presence maps by depth of segment
+	segments []segment // reserved presence maps of encoding segments
 
 	msg Sender
+	sender senderGuard // recovers panics of Sender methods
+	plans planCache // plans of reflection by message type
 
 	target io.Writer
+	offset int64 // count of bytes written to target
 
 	logger *writerLog
 	mu sync.Mutex
 }
 
+// segment is a position of reserved presence map in the buffer.
+type segment struct {
+	pos  int
+	size int // count of bits
+}
+
 // Reset resets dictionary
 func (e *Encoder) Reset() {
 	e.mu.Lock()
@@ -36,20 +48,79 @@ func (e *Encoder) Reset() {
 	e.storage = newStorage()
 }
 
+// ResetDictionary resets all values of dictionary by name. The name is "global"
+// or user defined name of dictionary.
+func (e *Encoder) ResetDictionary(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.storage.reset(name)
+}
+
+// ResetTemplate resets template dictionary and all values used by instructions
+// of template. It returns ErrD9 if template is unknown.
+func (e *Encoder) ResetTemplate(id uint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tpl, ok := e.repo[id]
+	if !ok {
+		return ErrD9
+	}
+	e.storage.resetTemplate(&tpl)
+	return nil
+}
+
+// ResetKey resets single value of dictionary by key.
+func (e *Encoder) ResetKey(dictionary, key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.storage.resetKey(dictionary, key)
+}
+
+// SetResetTemplate marks template as reset template. The dictionaries are reset
+// each time the message of template is encoded, all dictionaries are reset
+// if names are not specified.
+func (e *Encoder) SetResetTemplate(id uint, dictionaries ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resets[id] = dictionaries
+}
+
 // NewEncoder returns a new encoder that writes FAST-encoded message to writer.
 func NewEncoder(writer io.Writer, tmps ...*Template) *Encoder {
 	encoder := &Encoder{
 		repo: make(map[uint]Template),
-		storage: make(map[string]interface{}),
+		resets: make(map[uint][]string),
+		storage: newStorage(),
 		target: writer,
+		writer: newWriter(),
 		pmc: newPMapCollector(),
+		plans: newPlanCache(),
 	}
 	for _, t := range tmps {
-		encoder.repo[t.ID] = t.clone()
+		tpl := t.clone()
+		encoder.plans.number(tpl.Instructions)
+		encoder.repo[t.ID] = tpl
 	}
 	return encoder
 }
 
+// SetForceTemplateID sets mode of template identifier encoding. If force is true,
+// the identifier is written to each message, even if it equals the previous one.
+func (e *Encoder) SetForceTemplateID(force bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.forceTID = force
+}
+
+// SetBlockLength sets mode of block length preamble. If enabled, each message is
+// prefixed with stop bit encoded uInt32 count of bytes of the message.
+func (e *Encoder) SetBlockLength(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.blockLength = enabled
+}
+
 // SetLog sets writer for logging
 func (e *Encoder) SetLog(writer io.Writer) {
 	e.mu.Lock()
@@ -57,23 +128,30 @@ func (e *Encoder) SetLog(writer io.Writer) {
 
 	if writer != nil {
 		e.logger = wrapWriterLog(writer)
+		e.writer.log = writer
 		return
 	}
 
 	if e.logger != nil {
+		e.writer.log = nil
 		e.logger = nil
 	}
 }
 
 // Encode encodes msg struct to writer. If an encountered value implements the Sender interface
 // and is not a nil pointer, Encode calls method of Sender to produce encoded message.
-func (e *Encoder) Encode(msg interface{}) error {
+// The message is written to writer by single call of Write. If the call fails, Encode
+// returns Error caused by WriteError, which reports count of written bytes. The
+// dictionaries are not updated by a message which fails to encode or to be written,
+// but the encoder has to be reset if the receiver got a part of the message. Panic of
+// Sender is returned as Error caused by ErrPanic.
+func (e *Encoder) Encode(msg interface{}) (err error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	e.pmc.reset()
-	e.writers = []*writer{}
-	e.writerIndex = 0
+	e.segments = e.segments[:0]
+	e.writer.Reset()
 
 	if e.logger != nil {
 		e.logger.prefix = "\n"
@@ -81,59 +159,135 @@ func (e *Encoder) Encode(msg interface{}) error {
 
 	e.log("// ----- new message start ----- //")
 
+	e.tid = 0
 	var ok bool
-	if e.msg, ok = msg.(Sender); !ok {
-		e.msg = makeMsg(msg)
+	if e.sender.sender, ok = msg.(Sender); ok {
+		e.sender.err = nil
+		e.msg = &e.sender
+	} else if e.msg, err = makeMsg(msg, &e.plans); err != nil {
+		return &Error{Operator: OperatorNone, Offset: e.offset, Err: err}
+	}
+	if m, ok := msg.(*Message); ok {
+		m.reset()
 	}
 	e.tid = e.msg.GetTemplateID()
+	if err = e.msgErr(); err != nil {
+		return &Error{Operator: OperatorNone, Offset: e.offset, Err: err}
+	}
 
-	// TODO have to implement optional template id
 	tpl, ok := e.repo[e.tid]
 	if !ok {
-		return ErrD9
+		return &Error{TemplateID: e.tid, Operator: OperatorNone, Offset: e.offset, Err: ErrD9}
 	}
 
-	e.pmc.append(&pMap{mask: defaultMask})
-	e.addWriter()
-	e.log("template = ", e.tid)
-	e.log("  encoding -> ")
-	e.acceptTemplateID(uint32(e.tid))
+	preamble := -1
+	if e.blockLength {
+		preamble = e.writer.ReserveBlockLength()
+	}
 
-	err := e.encodeSegment(tpl.Instructions)
+	e.beginSegment(tpl.pMapSize)
+	if e.logger != nil {
+		e.log("template = ", e.tid)
+		e.log("  encoding -> ")
+	}
+	e.storage.begin()
+	err = e.acceptTemplateID(uint32(e.tid))
+	if err == nil {
+		err = e.encodeSegment(tpl.Instructions)
+	}
+	if err == nil {
+		err = e.msgErr() // error of the last method, e.g. Unlock
+	}
 	if err != nil {
-		return err
+		e.storage.rollback()
+		return templateError(err, &tpl, e.offset)
+	}
+
+	from := 0
+	if preamble >= 0 {
+		if e.logger != nil {
+			e.log("block length = ", e.writer.Len()-preamble-maxSize32)
+			e.log("  encoding -> ")
+		}
+		from = e.writer.PatchBlockLength(preamble)
+	}
+
+	offset := e.offset
+	if err = e.commit(from); err != nil {
+		e.storage.rollback()
+		return templateError(err, &tpl, offset)
+	}
+
+	if dictionaries, ok := e.resets[e.tid]; ok {
+		e.storage.resetDictionaries(dictionaries)
 	}
-	return e.commit()
+	return nil
 }
 
-func (e *Encoder) addWriter() {
-	if e.logger != nil {
-		e.writers = append(e.writers, newWriter(wrapWriterLog(e.logger.log), wrapWriterLog(e.logger.log)))
-	} else {
-		e.writers = append(e.writers, newWriter(&bytes.Buffer{}, &bytes.Buffer{}))
+// beginSegment appends presence map of size bits to collector and reserves space
+// for it in the buffer. The segment has no presence map if size is 0.
+func (e *Encoder) beginSegment(size int) {
+	if size == 0 {
+		e.pmc.append(nil)
+		return
+	}
+
+	depth := len(e.segments)
+	if depth == len(e.pmaps) {
+		e.pmaps = append(e.pmaps, new(pMap))
 	}
-	e.writerIndex = len(e.writers) -1
+
+	m := e.pmaps[depth]
+	*m = pMap{mask: 1 << uint(pMapBytes(size)*7)}
+	e.pmc.append(m)
+	e.segments = append(e.segments, segment{pos: e.writer.ReservePMap(size), size: size})
 }
 
-func (e *Encoder) delWriterTo(index int) {
-	if e.logger != nil {
-		e.log("rewrite from buffer <- ")
+// endSegment writes presence map of current segment to reserved space.
+func (e *Encoder) endSegment() {
+	m := e.pmc.current()
+	if m == nil {
+		return
 	}
-	for i:=index+1; i<=len(e.writers)-1; i++ {
-		e.writers[i].WriteTo(e.writers[index])
+
+	if e.logger != nil {
+		e.log("pmap = ", m)
+		e.log("  encoding -> ")
 	}
-	e.writers = e.writers[:index+1]
+	s := e.segments[len(e.segments)-1]
+	e.segments = e.segments[:len(e.segments)-1]
+	e.writer.PatchPMap(s.pos, s.size, m)
 }
 
-func (e *Encoder) commit() error {
-	// TODO have to check err
-	e.writers[e.writerIndex].WriteTo(e.target)
+// commit writes encoded message from position of buffer to target. It returns
+// WriteError if the message is not written completely.
+func (e *Encoder) commit(from int) error {
+	data := e.writer.Bytes()[from:]
+	size := len(data)
+	n, err := e.target.Write(data)
+	e.offset += int64(n)
+
+	if err == nil && n < size {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return &WriteError{Written: n, Size: size, Err: err}
+	}
 	return nil
 }
 
-func (e *Encoder) acceptTemplateID(id uint32) {
+// acceptTemplateID encodes template identifier with copy operator in global dictionary.
+// The identifier is omitted if it equals the previous one and force mode is disabled.
+func (e *Encoder) acceptTemplateID(id uint32) error {
+	previous, ok := e.storage.lookupTemplateID()
+	if !e.forceTID && ok && previous == uint(id) {
+		e.pmc.active().SetNextBit(false)
+		return nil
+	}
+
+	e.storage.saveTemplateID(uint(id))
 	e.pmc.active().SetNextBit(true)
-	_ = e.writers[e.writerIndex].WriteUint(false, uint64(id), maxSize32)
+	return e.writer.WriteUint(false, uint64(id))
 }
 
 func (e *Encoder) encodeSegment(instructions []*Instruction) error {
@@ -149,16 +303,27 @@ func (e *Encoder) encodeSegment(instructions []*Instruction) error {
 			err = e.encodeSequence(instruction)
 		case TypeGroup:
 			err = e.encodeGroup(instruction)
+		case TypeTemplateRef:
+			err = e.encodeTemplateRef()
+		case TypeBitGroup:
+			err = e.encodeBitGroup(instruction)
 		default:
 			field := acquireField()
 			field.ID = instruction.ID
 			field.Name = instruction.Name
+			field.instruction = instruction
 
 			e.msg.GetValue(field)
-			e.log(instruction.Name, " = ", field.Value)
-			e.log("  encoding -> ")
+			if err = e.msgErr(); err != nil {
+				releaseField(field)
+				break
+			}
+			if e.logger != nil {
+				e.log(instruction.Name, " = ", field.Value)
+				e.log("  encoding -> ")
+			}
 			err = instruction.inject(
-				e.writers[e.writerIndex],
+				e.writer,
 				e.storage,
 				e.pmc.active(),
 				field.Value,
@@ -167,16 +332,10 @@ func (e *Encoder) encodeSegment(instructions []*Instruction) error {
 		}
 
 		if err != nil {
-			return err
+			return wrapError(err, pathName(instruction), instruction.Operator, e.offset)
 		}
 	}
-	e.log("pmap = ", e.pmc.current())
-	e.log("  encoding -> ")
-
-	if m := e.pmc.current(); m != nil {
-		_ = e.writers[e.writerIndex].WritePMap(m)
-	}
-
+	e.endSegment()
 	return nil
 }
 
@@ -185,32 +344,106 @@ func (e *Encoder) encodeGroup(instruction *Instruction) error {
 	parent := acquireField()
 	parent.ID = instruction.ID
 	parent.Name = instruction.Name
+	parent.instruction = instruction
 
 	if instruction.isOptional() {
 		e.pmc.active().SetNextBit(true)
 	}
 
-	current := e.writerIndex // remember current writer index
+	e.beginSegment(instruction.pMapSize)
 
-	var pmap *pMap
-	if instruction.pMapSize > 0 {
-		pmap = &pMap{mask: defaultMask}
+	locked := e.msg.Lock(parent)
+	if err := e.msgErr(); err != nil {
+		return err
+	}
+	err := e.encodeSegment(instruction.Instructions)
+	if err != nil {
+		return err
 	}
+	if locked {
+		e.msg.Unlock()
+	}
+	releaseField(parent)
 
-	e.pmc.append(pmap)
-	e.addWriter()
+	e.pmc.restore()
+	return nil
+}
 
-	e.msg.Lock(parent)
-	err := e.encodeSegment(instruction.Instructions)
+// encodeBitGroup encodes boolean fields of group as bits of unsigned integer.
+func (e *Encoder) encodeBitGroup(instruction *Instruction) error {
+	parent := acquireField()
+	parent.ID = instruction.ID
+	parent.Name = instruction.Name
+	parent.instruction = instruction
+
+	var value interface{}
+	if e.msg.Lock(parent) {
+		var bits uint64
+		for index, inner := range instruction.Instructions {
+			field := acquireField()
+			field.ID = inner.ID
+			field.Name = inner.Name
+			field.instruction = inner
+			e.msg.GetValue(field)
+			if set, _ := field.Value.(bool); set {
+				bits |= 1 << uint(index)
+			}
+			releaseField(field)
+		}
+		e.msg.Unlock()
+		value = bits
+	}
+	releaseField(parent)
+	if err := e.msgErr(); err != nil {
+		return err
+	}
+
+	if e.logger != nil {
+		e.log("bit group = ", value)
+		e.log("  encoding -> ")
+	}
+	return instruction.inject(e.writer, e.storage, e.pmc.active(), value)
+}
+
+// encodeTemplateRef encodes nested message of dynamic template reference.
+func (e *Encoder) encodeTemplateRef() error {
+	e.log("template reference start: ")
+	parent := acquireField()
+	defer releaseField(parent)
+	parent.Name = tagTemplateRef
+
+	locked := e.msg.Lock(parent)
+	if err := e.msgErr(); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrD9
+	}
+
+	tid := e.msg.GetTemplateID()
+	if err := e.msgErr(); err != nil {
+		return err
+	}
+	tpl, ok := e.repo[tid]
+	if !ok {
+		return ErrD9
+	}
+
+	e.beginSegment(tpl.pMapSize)
+	if e.logger != nil {
+		e.log("template = ", tid)
+		e.log("  encoding -> ")
+	}
+	err := e.acceptTemplateID(uint32(tid))
+	if err == nil {
+		err = e.encodeSegment(tpl.Instructions)
+	}
 	if err != nil {
 		return err
 	}
 	e.msg.Unlock()
-	releaseField(parent)
 
 	e.pmc.restore()
-	e.delWriterTo(current)
-	e.writerIndex = current // restore index
 	return nil
 }
 
@@ -218,50 +451,66 @@ func (e *Encoder) encodeSequence(instruction *Instruction) error {
 	parent := acquireField()
 	parent.ID = instruction.ID
 	parent.Name = instruction.Name
+	parent.instruction = instruction
 
 	e.msg.GetLength(parent)
-	length := parent.Value.(int)
+	if err := e.msgErr(); err != nil {
+		return err
+	}
+	length, ok := parent.Value.(int)
+	if !ok {
+		releaseField(parent)
+		if !instruction.isOptional() {
+			return ErrMissingValue
+		}
+		e.log("sequence is absent")
+		return instruction.Instructions[0].inject(e.writer, e.storage, e.pmc.active(), nil)
+	}
 
-	e.log("sequence start: ")
-	e.log("  length = ", length)
-	e.log("    encoding -> ")
+	if e.logger != nil {
+		e.log("sequence start: ")
+		e.log("  length = ", length)
+		e.log("    encoding -> ")
+	}
 	err := instruction.Instructions[0].inject(
-		e.writers[e.writerIndex],
+		e.writer,
 		e.storage,
 		e.pmc.active(),
 		uint32(length),
 	)
 	if err != nil {
-		return err
+		return wrapError(err, pathName(instruction.Instructions[0]), instruction.Instructions[0].Operator, e.offset)
 	}
 
-	current := e.writerIndex // remember current writer index
 	for i:=0; i<length; i++ {
 		parent.Value = i
-		e.log("sequence elem[", i, "] start: ")
-
-		var pmap *pMap
-		if instruction.pMapSize > 0 {
-			pmap = &pMap{mask: defaultMask}
+		if e.logger != nil {
+			e.log("sequence elem[", i, "] start: ")
 		}
 
-		e.pmc.append(pmap)
-		e.addWriter()
+		e.beginSegment(instruction.pMapSize)
 
-		e.msg.Lock(parent)
-		err = e.encodeSegment(instruction.Instructions[1:])
+		locked := e.msg.Lock(parent)
+		if err = e.msgErr(); err == nil {
+			err = e.encodeSegment(instruction.Instructions[1:])
+		}
 		if err != nil {
-			return err
+			return wrapError(err, elemName(i), OperatorNone, e.offset)
+		}
+		if locked {
+			e.msg.Unlock()
 		}
-		e.msg.Unlock()
 		e.pmc.restore()
-		e.delWriterTo(current)
 	}
 	releaseField(parent)
-	e.writerIndex = current // restore index
 	return nil
 }
 
+// msgErr returns error of reflection or panic of Sender method.
+func (e *Encoder) msgErr() error {
+	return e.msg.(flusher).flushErr()
+}
+
 func (e *Encoder) log(param ...interface{}) {
 	if e.logger == nil {
 		return
@@ -0,0 +1,38 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !race
+// +build !race
+
+package fast_test
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestEncoderAllocs is not run with race detector, which drops items of sync.Pool.
+func TestEncoderAllocs(t *testing.T) {
+	msgs := benchSenders(t)
+	e := newEncoder(ioutil.Discard, t)
+
+	for _, msg := range msgs {
+		if err := e.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var err error
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, msg := range msgs {
+			err = e.Encode(msg)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocs > 0 {
+		t.Fatal("encoder allocates", allocs, "times")
+	}
+}
@@ -6,7 +6,10 @@ package fast_test
 
 import (
 	"bytes"
+	"errors"
 	"github.com/co11ter/goFAST"
+	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -49,6 +52,11 @@ func TestDecimalEncode(t *testing.T) {
 	//encode(&decimalMessage2, decimalData2, t)
 }
 
+func TestDecimalValueEncode(t *testing.T) {
+	encoder.Reset()
+	encode(&decimalValueMessage1, decimalData1, t)
+}
+
 func TestSequenceEncode(t *testing.T) {
 	encode(&sequenceMessage1, sequenceData1, t)
 }
@@ -59,12 +67,347 @@ func TestByteVectorEncode(t *testing.T) {
 
 func TestStringEncode(t *testing.T) {
 	encode(&stringMessage1, stringData1, t)
+	encode(&stringMessage2, stringData2, t)
 }
 
 func TestIntegerEncode(t *testing.T) {
 	encode(&integerMessage1, integerData1, t)
+	encode(&integerMessage2, integerData2, t)
+}
+
+func TestTailEncode(t *testing.T) {
+	encode(&tailMessage1, tailData1, t)
+	encode(&tailMessage2, tailData2, t)
+	encode(&tailMessage3, tailData3, t)
+	encode(&tailMessage4, tailData4, t) // initial value is the base after empty previous value
+}
+
+func TestDeltaEncode(t *testing.T) {
+	encode(&deltaMessage1, deltaData1, t)
+	encode(&deltaMessage2, deltaData2, t)
+	encode(&deltaMessage3, deltaData3, t)
+}
+
+func TestStaticReferenceEncode(t *testing.T) {
+	encode(&staticReferenceMessage1, staticReferenceData1, t)
+}
+
+func TestDynamicReferenceEncode(t *testing.T) {
+	encode(&dynamicReferenceMessage1, dynamicReferenceData1, t)
+}
+
+func TestDictionaryEncode(t *testing.T) {
+	encoder.Reset()
+	encode(&dictionaryMessage1, dictionaryData1, t)
+	encode(&dictionaryMessage2, dictionaryData2, t)
+	encode(&dictionaryMessage3, dictionaryData3, t)
+}
+
+func TestResetEncode(t *testing.T) {
+	encoder.Reset()
+	encode(&dictionaryMessage1, dictionaryData1, t)
+
+	if err := encoder.ResetTemplate(12); err != nil {
+		t.Fatal(err)
+	}
+	encode(&dictionaryMessage1, dictionaryData5, t)
+
+	encoder.SetResetTemplate(14)
+	encode(&resetMessage1, resetData1, t)
+	encode(&dictionaryMessage1, dictionaryData1, t)
+
+	encoder.ResetKey("global", "GlobalKey")
+	encode(&dictionaryMessage3, dictionaryData6, t)
+
+	encoder.ResetDictionary("global")
+	encode(&dictionaryMessage3, dictionaryData4, t)
+	encode(&dictionaryMessage3, dictionaryData7, t)
+
+	if err := encoder.ResetTemplate(100); err != fast.ErrD9 {
+		t.Fatal("expected ErrD9, got", err)
+	}
+}
+
+func TestTemplateIDEncode(t *testing.T) {
+	encoder.Reset()
+	encode(&dictionaryMessage1, dictionaryData1, t)
+	encode(&dictionaryMessage3, dictionaryData7, t)
+
+	encoder.SetForceTemplateID(true)
+	defer encoder.SetForceTemplateID(false)
+	encode(&dictionaryMessage3, dictionaryData3, t)
+}
+
+func TestBlockLengthEncode(t *testing.T) {
+	var buf bytes.Buffer
+	e := newEncoder(&buf, t)
+	e.SetBlockLength(true)
+	if err := e.Encode(&decimalMessage1); err != nil {
+		t.Fatal("can not encode", err)
+	}
+
+	expect := append([]byte{0x8d}, decimalData1...)
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+}
+
+func TestErrorEncode(t *testing.T) {
+	err := encoder.Encode(&headerType{TemplateID: 100})
+	if !errors.Is(err, fast.ErrD9) {
+		t.Fatal("expected ErrD9, got", err)
+	}
+
+	var e *fast.Error
+	if !errors.As(err, &e) || e.TemplateID != 100 {
+		t.Fatal("expected error of template 100, got", err)
+	}
+}
+
+// panicSender panics on GetValue.
+type panicSender struct {
+	headerType
+}
+
+func (s *panicSender) GetTemplateID() uint { return 9 }
+func (s *panicSender) GetValue(*fast.Field) { panic("no value") }
+func (s *panicSender) GetLength(*fast.Field) {}
+func (s *panicSender) Lock(*fast.Field) bool { return false }
+func (s *panicSender) Unlock() {}
+
+type duplicateTagType struct {
+	TemplateID uint   `fast:"*"`
+	SeqNum     uint32 `fast:"34"`
+	Number     uint32 `fast:"34"`
+}
+
+type intSequenceType struct {
+	TemplateID    uint `fast:"*"`
+	OuterSequence int
+}
+
+type missingSequenceType struct {
+	TemplateID uint `fast:"*"`
+	TestData   uint32
+}
+
+type missingDecimalType struct {
+	TemplateID  uint `fast:"*"`
+	CopyDecimal *float64
+}
+
+type missingDeltaType struct {
+	TemplateID uint `fast:"*"`
+	DeltaAscii *string
+}
+
+func TestMessageErrorEncode(t *testing.T) {
+	cases := []struct {
+		msg interface{}
+		err error
+	}{
+		{headerType{TemplateID: 9}, fast.ErrMessageType},
+		{(*headerType)(nil), fast.ErrMessageType},
+		{&duplicateTagType{TemplateID: 9}, fast.ErrMessageType},
+		{&intSequenceType{TemplateID: 2}, fast.ErrMessageType},
+		{&missingSequenceType{TemplateID: 2}, fast.ErrMissingValue},
+		{&missingDecimalType{TemplateID: 1}, fast.ErrMissingValue},
+		{&missingDeltaType{TemplateID: 8}, fast.ErrMissingValue},
+		{&panicSender{}, fast.ErrPanic},
+	}
+	for _, c := range cases {
+		if err := newEncoder(ioutil.Discard, t).Encode(c.msg); !errors.Is(err, c.err) {
+			t.Fatalf("%T: expected %v, got %v", c.msg, c.err, err)
+		}
+	}
+}
+
+func TestSharedKeyEncode(t *testing.T) {
+	e := fast.NewEncoder(ioutil.Discard, sharedKeyTemplates(t)...)
+	if err := e.Encode(&sharedUintType{TemplateID: 1, Shared: 5}); err != nil {
+		t.Fatal("can not encode", err)
+	}
+
+	// previous value of string field is uInt32
+	if err := e.Encode(&sharedStringType{TemplateID: 2, Shared: "a"}); !errors.Is(err, fast.ErrD4) {
+		t.Fatal("expected", fast.ErrD4, "got", err)
+	}
+}
+
+func TestTemplateIDFieldEncode(t *testing.T) {
+	var buf bytes.Buffer
+	e := fast.NewEncoder(&buf, templateIDFieldTemplates(t)...)
+
+	msg := templateIDFieldType{TemplateID: 1, Field: 5}
+	for _, expect := range [][]byte{{0xe0, 0x81, 0x85}, {0x80}} {
+		if err := e.Encode(&msg); err != nil {
+			t.Fatal("can not encode", err)
+		}
+		if !bytes.Equal(buf.Bytes(), expect) {
+			t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+		}
+		buf.Reset()
+	}
+}
+
+type limitWriter struct {
+	n   int
+	err error
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, w.err
+	}
+	return len(p), nil
+}
+
+func TestWriteErrorEncode(t *testing.T) {
+	for _, w := range []*limitWriter{{n: 2, err: io.ErrClosedPipe}, {n: 3}} {
+		e := newEncoder(w, t)
+		err := e.Encode(&decimalMessage1)
+
+		var we *fast.WriteError
+		if !errors.As(err, &we) {
+			t.Fatal("expected write error, got", err)
+		}
+		if we.Written != w.n || we.Size != len(decimalData1) {
+			t.Fatalf("expected %d of %d written bytes, got %d of %d", w.n, len(decimalData1), we.Written, we.Size)
+		}
+
+		expect := w.err
+		if expect == nil {
+			expect = io.ErrShortWrite
+		}
+		if !errors.Is(err, expect) {
+			t.Fatal("expected", expect, "got", err)
+		}
+	}
+}
+
+// failWriter fails writes while fail is set.
+type failWriter struct {
+	bytes.Buffer
+	fail bool
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, io.ErrClosedPipe
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestRollbackEncode(t *testing.T) {
+	tpls := rollbackTemplates(t)
+	w := &failWriter{}
+	e := fast.NewEncoder(w, tpls...)
+
+	if err := e.Encode(&rollbackType{TemplateID: 1, A: 5}); !errors.Is(err, fast.ErrMissingValue) {
+		t.Fatal("expected", fast.ErrMissingValue, "got", err)
+	}
+
+	b := 1.5
+	msg := rollbackType{TemplateID: 1, A: 5, B: &b}
+	w.fail = true
+	var we *fast.WriteError
+	if err := e.Encode(&msg); !errors.As(err, &we) {
+		t.Fatal("expected write error, got", err)
+	}
+
+	// failed messages do not update dictionaries, so template id and A are encoded
+	w.fail = false
+	if err := e.Encode(&msg); err != nil {
+		t.Fatal("can not encode", err)
+	}
+
+	var decoded rollbackType
+	if _, err := fast.NewDecoder(nil, tpls...).DecodeBytes(w.Bytes(), &decoded); err != nil {
+		t.Fatal("can not decode", err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+	}
 }
 
 func TestGroupEncode(t *testing.T) {
 	encode(&groupMessage1, groupData1, t)
-}
\ No newline at end of file
+}
+
+// write profile command: go test -bench=BenchmarkEncoder_EncodeReflection -cpuprofile=cpu.out -memprofile=mem.out
+// convert to cpuprof.pdf command: go tool pprof -pdf -output=cpuprof.pdf goFAST.test cpu.out
+// convert to memprof.pdf command: go tool pprof -pdf -output=memprof.pdf goFAST.test mem.out
+func BenchmarkEncoder_EncodeReflection(b *testing.B) {
+	var msgs []interface{}
+	for _, data := range benchMessages(b) {
+		var msg benchmarkMessage
+		if _, err := newDecoder(nil, b).DecodeBytes(data, &msg); err != nil {
+			b.Fatal(err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	benchEncode(b, msgs)
+}
+
+func BenchmarkEncoder_EncodeSender(b *testing.B) {
+	benchEncode(b, benchSenders(b))
+}
+
+func benchEncode(b *testing.B, msgs []interface{}) {
+	e := newEncoder(ioutil.Discard, b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(msgs[i%len(msgs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportAllocs()
+}
+
+// benchMessages returns data of messages of testdata/data.dat.
+func benchMessages(t testing.TB) (msgs [][]byte) {
+	data, err := ioutil.ReadFile("testdata/data.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDecoder(nil, t)
+	for pos := 0; pos < len(data); {
+		var msg benchmarkMessage
+		n, err := d.DecodeBytes(data[pos+4:], &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, data[pos+4:pos+4+n])
+		pos += 4 + n
+	}
+	return
+}
+
+func benchSenders(t testing.TB) (msgs []interface{}) {
+	d := newDecoder(nil, t)
+	for _, data := range benchMessages(t) {
+		msg := newBenchmarkSender()
+		if _, err := d.DecodeBytes(data, msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return
+}
+
+// newEncoder returns encoder of test templates.
+func newEncoder(w io.Writer, t testing.TB) *fast.Encoder {
+	ftpl, err := os.Open("testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	tpls, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fast.NewEncoder(w, tpls...)
+}
@@ -4,13 +4,17 @@
 
 package fast
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
 
 var (
 	// ErrS1 is a static error if templates encoded in the concrete XML syntax are in
 	// fact not well-formed, do not follow the rules of XML namespaces or are invalid
 	// with respect to the schema in Appendix 1 in FAST 1.1 specification.
-	// TODO have to find native lib to check xml syntax
 	ErrS1 = errors.New("static error: S1")
 
 	// ErrS2 is a static error if an operator is specified for a field of a type to
@@ -108,4 +112,200 @@ var (
 
 	// ErrR9 is a reportable error if a string appears in an overlong encoding.
 	ErrR9 = errors.New("reportable error: R9")
+
+	// ErrBlockLength is an error if a block length preamble does not match the count
+	// of bytes of the message.
+	ErrBlockLength = errors.New("error: block length does not match message length")
+
+	// ErrTailLength is an error if a value is shorter than the base value of the tail
+	// operator, so the value can not be encoded by a tail.
+	ErrTailLength = errors.New("error: value is shorter than tail base value")
+
+	// ErrMessageType is an error if a message is not a non-nil pointer to struct or
+	// the struct does not match the template, e.g. it has duplicate fields or a group
+	// is mapped to a field, which is not a struct.
+	ErrMessageType = errors.New("error: invalid type of message")
+
+	// ErrMissingValue is an error if a message has no value of a mandatory field,
+	// which can not be encoded as zero, e.g. mandatory decimal, sequence or field with
+	// delta operator.
+	ErrMissingValue = errors.New("error: value of mandatory field is missing")
+
+	// ErrPanic is an error if a method of Sender or Receiver panics. The error
+	// contains the value of panic.
+	ErrPanic = errors.New("error: panic in message method")
 )
+
+// Error is an error of decoding or encoding of message. It contains context of
+// error and the cause, which can be checked by errors.Is.
+type Error struct {
+	TemplateID   uint
+	TemplateName string
+	Path         string // path of instruction, e.g. MDEntries[3].MDEntryPx.mantissa
+	Operator     InstructionOperator
+	Offset       int64 // offset in stream, it is the offset of message start for encoder
+	Err          error
+}
+
+func (e *Error) Error() string {
+	msg := "fast: template " + strconv.Itoa(int(e.TemplateID))
+	if e.TemplateName != "" {
+		msg += " (" + e.TemplateName + ")"
+	}
+	if e.Path != "" {
+		msg += ", field " + e.Path
+	}
+	if e.Operator != OperatorNone {
+		msg += ", operator " + e.Operator.String()
+	}
+	return msg + ", offset " + strconv.FormatInt(e.Offset, 10) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WriteError is an error of writing encoded message to io.Writer. The message is
+// written partially if Written is greater than 0, io.ErrShortWrite is the cause
+// if writer returns no error for short write.
+type WriteError struct {
+	Written int // count of bytes of message written to target
+	Size    int // count of bytes of encoded message
+	Err     error
+}
+
+func (e *WriteError) Error() string {
+	return "write " + strconv.Itoa(e.Written) + " of " + strconv.Itoa(e.Size) + " bytes: " + e.Err.Error()
+}
+
+// Unwrap returns the cause of error.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateError is a static error of template. Line and Column are the position
+// of the end of the start tag in XML, they are zero for templates defined in code.
+type TemplateError struct {
+	Line     int
+	Column   int
+	Template string // name of template
+	Path     string // path of instruction, e.g. MDEntries.MDEntryPx
+	Msg      string // description of problem
+	Err      error  // static error, e.g. ErrS1
+}
+
+func (e *TemplateError) Error() string {
+	msg := "fast: "
+	if e.Line > 0 {
+		msg += "line " + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			msg += ", column " + strconv.Itoa(e.Column)
+		}
+		msg += ": "
+	}
+	if e.Template != "" {
+		msg += "template " + e.Template + ": "
+	}
+	if e.Path != "" {
+		msg += "field " + e.Path + ": "
+	}
+	if e.Msg != "" {
+		msg += e.Msg + ": "
+	}
+	return msg + e.Err.Error()
+}
+
+// Unwrap returns the cause of error.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateErrors contains all static errors of templates. Each of them can be
+// checked by errors.Is.
+type TemplateErrors []*TemplateError
+
+func (e TemplateErrors) Error() string {
+	msg := ""
+	for i, err := range e {
+		if i > 0 {
+			msg += "\n"
+		}
+		msg += err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns errors of templates.
+func (e TemplateErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// messageTypeError returns ErrMessageType with description of problem.
+func messageTypeError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrMessageType}, args...)...)
+}
+
+// panicError returns error caused by ErrPanic for recovered value of panic.
+func panicError(recovered interface{}) error {
+	return fmt.Errorf("%w: %v", ErrPanic, recovered)
+}
+
+// wrapError adds name of instruction to the path of err. The err is wrapped by
+// Error with operator and offset if it is not Error yet.
+func wrapError(err error, name string, operator InstructionOperator, offset int64) error {
+	e, ok := err.(*Error)
+	if !ok {
+		return &Error{Path: name, Operator: operator, Offset: offset, Err: err}
+	}
+
+	switch {
+	case e.Path == "":
+		e.Path = name
+	case name == "":
+	case e.Path[0] == '[':
+		e.Path = name + e.Path
+	default:
+		e.Path = name + "." + e.Path
+	}
+	return e
+}
+
+// templateError sets template of err. The err is wrapped by Error if it is not Error yet.
+// It must not be called for io.EOF at the start of message.
+func templateError(err error, tpl *Template, offset int64) error {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Operator: OperatorNone, Offset: offset, Err: err}
+	}
+	if e.Err == io.EOF {
+		e.Err = io.ErrUnexpectedEOF // message is not completed
+	}
+	if tpl != nil {
+		e.TemplateID = tpl.ID
+		e.TemplateName = tpl.Name
+	}
+	return e
+}
+
+// pathName returns name of instruction in path of error.
+func pathName(instruction *Instruction) string {
+	switch {
+	case instruction.Name != "":
+		return instruction.Name
+	case instruction.Type == TypeTemplateRef:
+		return tagTemplateRef
+	case instruction.Type == TypeLength:
+		return tagLength
+	}
+	return ""
+}
+
+// elemName returns name of sequence element in path of error.
+func elemName(index int) string {
+	return "[" + strconv.Itoa(index) + "]"
+}
@@ -117,5 +117,5 @@ func ExampleEncoder_Encode_reflection() {
 	}
 	fmt.Printf("%x", buf.Bytes())
 
-	// Output: c081746573f4808182
+	// Output: c081746573f480808182
 }
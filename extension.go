@@ -0,0 +1,192 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast
+
+import (
+	"math"
+	"time"
+)
+
+// Types of FAST 1.2 are encoded by integers:
+//	boolean   - uInt32, 0 is false and 1 is true
+//	enum      - uInt32, index of element
+//	set       - uInt64, bit i is set for element i
+//	bitGroup  - uInt64, bit i is value of boolean field i
+//	timestamp - int64, count of units since Unix epoch
+//	time      - uInt64, count of units since midnight
+//	date      - int32, count of days since Unix epoch
+// Timestamp, time and date values are time.Time in UTC.
+
+const day = 24 * time.Hour
+
+// epoch is the date of time values.
+var epoch = time.Unix(0, 0).UTC()
+
+var units = map[string]time.Duration{
+	"second":      time.Second,
+	"millisecond": time.Millisecond,
+	"microsecond": time.Microsecond,
+	"nanosecond":  time.Nanosecond,
+}
+
+// unit returns unit of timestamp and time, millisecond is default.
+func (i *Instruction) unit() time.Duration {
+	if i.Unit > 0 {
+		return i.Unit
+	}
+	return time.Millisecond
+}
+
+// unitName returns name of unit in XML template.
+func unitName(unit time.Duration) string {
+	for name, value := range units {
+		if value == unit {
+			return name
+		}
+	}
+	return ""
+}
+
+// indexOf returns index of element or -1 if it is not found.
+func indexOf(elements []string, name string) int {
+	for index, element := range elements {
+		if element == name {
+			return index
+		}
+	}
+	return -1
+}
+
+// writeExtension writes value of FAST 1.2 type.
+func (i *Instruction) writeExtension(writer *writer, value interface{}) error {
+	nullable := i.isNullable()
+	switch i.Type {
+	case TypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return ErrD1
+		}
+		if b {
+			return writer.WriteUint(nullable, 1)
+		}
+		return writer.WriteUint(nullable, 0)
+	case TypeEnum:
+		name, _ := value.(string)
+		index := indexOf(i.Elements, name)
+		if index < 0 {
+			return ErrD1
+		}
+		return writer.WriteUint(nullable, uint64(index))
+	case TypeSet:
+		names, _ := value.([]string)
+		bits, ok := i.setBits(names)
+		if !ok {
+			return ErrD1
+		}
+		return writer.WriteUint(nullable, bits)
+	case TypeBitGroup:
+		return writer.WriteUint(nullable, value.(uint64))
+	}
+
+	t, ok := value.(time.Time)
+	if !ok {
+		return ErrD1
+	}
+	t = t.UTC()
+
+	switch i.Type {
+	case TypeTimestamp:
+		unit := int64(i.unit())
+		count := t.Unix()*int64(time.Second/i.unit()) + int64(t.Nanosecond())/unit
+		return writer.WriteInt(nullable, count)
+	case TypeTime:
+		hour, min, sec := t.Clock()
+		since := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute +
+			time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+		return writer.WriteUint(nullable, uint64(since/i.unit()))
+	case TypeDate:
+		year, month, date := t.Date()
+		days := time.Date(year, month, date, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+		return writer.WriteInt(nullable, days)
+	}
+	return nil
+}
+
+// readExtension reads value of FAST 1.2 type.
+func (i *Instruction) readExtension(reader *reader) (interface{}, error) {
+	nullable := i.isNullable()
+	switch i.Type {
+	case TypeTimestamp, TypeDate:
+		tmp, err := reader.ReadInt(nullable)
+		if err != nil || tmp == nil {
+			return nil, err
+		}
+
+		if i.Type == TypeDate {
+			if *tmp > math.MaxInt32 || *tmp < math.MinInt32 {
+				return nil, ErrD2
+			}
+			return epoch.AddDate(0, 0, int(*tmp)), nil
+		}
+
+		perSecond := int64(time.Second / i.unit())
+		sec, rest := *tmp/perSecond, *tmp%perSecond
+		return time.Unix(sec, rest*int64(i.unit())).UTC(), nil
+	}
+
+	tmp, err := reader.ReadUint(nullable)
+	if err != nil || tmp == nil {
+		return nil, err
+	}
+	value := *tmp
+
+	switch i.Type {
+	case TypeBoolean:
+		if value > 1 {
+			return nil, ErrD2
+		}
+		return value == 1, nil
+	case TypeEnum:
+		if value >= uint64(len(i.Elements)) {
+			return nil, ErrD2
+		}
+		return i.Elements[value], nil
+	case TypeSet:
+		names := make([]string, 0, len(i.Elements))
+		for index, name := range i.Elements {
+			if value&(1<<uint(index)) != 0 {
+				names = append(names, name)
+			}
+		}
+		if len(i.Elements) < 64 && value>>uint(len(i.Elements)) != 0 {
+			return nil, ErrD2
+		}
+		return names, nil
+	case TypeBitGroup:
+		if len(i.Instructions) < 64 && value>>uint(len(i.Instructions)) != 0 {
+			return nil, ErrD2
+		}
+		return value, nil
+	case TypeTime:
+		since := time.Duration(value) * i.unit()
+		if value > uint64(day/i.unit()) || since >= day {
+			return nil, ErrD2
+		}
+		return epoch.Add(since), nil
+	}
+	return nil, nil
+}
+
+// setBits returns bits of set elements, ok is false for unknown element.
+func (i *Instruction) setBits(names []string) (bits uint64, ok bool) {
+	for _, name := range names {
+		index := indexOf(i.Elements, name)
+		if index < 0 {
+			return 0, false
+		}
+		bits |= 1 << uint(index)
+	}
+	return bits, true
+}
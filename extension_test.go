@@ -0,0 +1,174 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/co11ter/goFAST"
+)
+
+const xmlExtension = `
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Extension" id="1">
+		<boolean name="Active" id="1"/>
+		<enum name="Side" id="2">
+			<element name="Buy"/>
+			<element name="Sell"/>
+		</enum>
+		<enum name="Kind" id="3">
+			<element name="A"/>
+			<element name="B"/>
+			<element name="C"/>
+		</enum>
+		<set name="Flags" id="4" presence="optional">
+			<element name="X"/>
+			<element name="Y"/>
+			<element name="Z"/>
+		</set>
+		<set name="Mask" id="5">
+			<element name="X"/>
+			<element name="Y"/>
+		</set>
+		<timestamp name="Sent" id="6" unit="microsecond"/>
+		<time name="At" id="7"/>
+		<date name="Day" id="8"/>
+		<bitGroup name="Status" id="9">
+			<boolean name="Open" id="10"/>
+			<boolean name="Halted" id="11"/>
+		</bitGroup>
+		<boolean name="Fast" id="12"><copy value="true"/></boolean>
+	</template>
+</templates>`
+
+type (
+	active bool
+	side   string
+	kind   int
+	flags  []string
+)
+
+type extension struct {
+	TemplateID uint `fast:"*"`
+	Active     active
+	Side       side
+	Kind       kind
+	Flags      flags
+	Mask       uint8
+	Sent       time.Time
+	At         time.Time
+	Day        time.Time
+	Status     struct {
+		Open   bool
+		Halted bool
+	}
+	Fast bool
+}
+
+func TestExtension(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := extension{
+		TemplateID: 1,
+		Active:     true,
+		Side:       "Sell",
+		Kind:       2,
+		Flags:      flags{"X", "Z"},
+		Mask:       3,
+		Sent:       time.Unix(0, 5000).UTC(),
+		At:         time.Unix(0, 0).Add(100 * time.Millisecond).UTC(),
+		Day:        time.Date(1970, 1, 3, 0, 0, 0, 0, time.UTC),
+		Fast:       true,
+	}
+	msg.Status.Open = true
+
+	var buf bytes.Buffer
+	if err = fast.NewEncoder(&buf, tpls...).Encode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	// pmap, tid, boolean, enums, sets, timestamp, time, date, bit group
+	expect := []byte{0xc0, 0x81, 0x81, 0x81, 0x82, 0x86, 0x83, 0x85, 0xe4, 0x82, 0x81}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+
+	var decoded extension
+	if err = fast.NewDecoder(&buf, tpls...).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+	}
+
+	msg.Side = "Hold"
+	if err = fast.NewEncoder(&buf, tpls...).Encode(&msg); !errors.Is(err, fast.ErrD1) {
+		t.Fatal("expected", fast.ErrD1, "got", err)
+	}
+}
+
+func TestExtensionMessage(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte{0xc0, 0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x81, 0x81, 0xff, 0x82}
+	var msg fast.Message
+	if err = fast.NewDecoder(bytes.NewReader(data), tpls...).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]interface{}{
+		"Active": false,
+		"Side":   "Buy",
+		"Kind":   "A",
+		"Mask":   []string{},
+		"Sent":   time.Unix(0, 1000).UTC(),
+		"At":     time.Unix(0, 0).Add(time.Millisecond).UTC(),
+		"Day":    time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		"Fast":   true,
+	}
+	for name, value := range expect {
+		if current, _ := msg.Get(name); !reflect.DeepEqual(current, value) {
+			t.Fatal(name, "expected", value, "got", current)
+		}
+	}
+	if value, _ := msg.Get("Flags"); value != nil {
+		t.Fatal("expected absent Flags, got", value)
+	}
+
+	status, _ := msg.Get("Status")
+	if halted, _ := status.(*fast.Message).Get("Halted"); halted != true {
+		t.Fatal("expected halted status, got", halted)
+	}
+}
+
+func TestWriteXMLTemplateExtension(t *testing.T) {
+	expect, err := fast.ParseXMLTemplate(strings.NewReader(xmlExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = fast.WriteXMLTemplate(&buf, expect); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := fast.ParseXMLTemplate(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, expect) {
+		t.Fatal("templates are not equal after writing")
+	}
+}
@@ -5,7 +5,12 @@
 package fast_test
 
 import (
+	"math/big"
+	"strings"
+	"testing"
+
 	fast "github.com/co11ter/goFAST"
+	"github.com/shopspring/decimal"
 )
 
 type decimalType struct {
@@ -17,6 +22,29 @@ type decimalType struct {
 	IndividualDecimalOptExpNotPresent *float64
 }
 
+type decimalValueType struct {
+	TemplateID           uint `fast:"*"`
+	CopyDecimal          fast.Decimal
+	MandatoryDecimal     decimal.Decimal
+	IndividualDecimal    string
+	IndividualDecimalOpt *big.Rat
+}
+
+type (
+	decimalPrice  float64
+	decimalText   string
+	decimalAmount decimal.Decimal
+	decimalRatio  big.Rat
+)
+
+type decimalNamedType struct {
+	TemplateID           uint `fast:"*"`
+	CopyDecimal          decimalPrice
+	MandatoryDecimal     decimalAmount
+	IndividualDecimal    decimalText
+	IndividualDecimalOpt *decimalRatio
+}
+
 type sequenceType struct {
 	TemplateID    uint `fast:"*"`
 	TestData      uint32
@@ -68,6 +96,54 @@ type groupType struct {
 	}
 }
 
+type tailType struct {
+	TemplateID  uint `fast:"*"`
+	TailAscii   string
+	TailUnicode *string
+	TailVector  []byte
+}
+
+type deltaType struct {
+	TemplateID   uint `fast:"*"`
+	DeltaAscii   string
+	DeltaUnicode *string
+	DeltaVector  []byte
+	DeltaUint32  uint32
+}
+
+type headerType struct {
+	TemplateID   uint `fast:"*"`
+	HeaderSeqNum uint32
+}
+
+type staticReferenceType struct {
+	TemplateID   uint `fast:"*"`
+	HeaderSeqNum uint32
+	RefData      string
+}
+
+type dynamicReferenceType struct {
+	TemplateID uint `fast:"*"`
+	OuterData  uint32
+	Nested     *headerType `fast:"templateRef"`
+}
+
+type dictionaryAType struct {
+	TemplateID uint `fast:"*"`
+	Shared     uint32
+	Global     uint32
+}
+
+type dictionaryBType struct {
+	TemplateID  uint `fast:"*"`
+	Shared      uint32
+	OtherGlobal uint32
+}
+
+type resetType struct {
+	TemplateID uint `fast:"*"`
+}
+
 type benchmarkMessage struct {
 	TemplateID     uint   `fast:"*"`
 	MessageType    string `fast:"35"`
@@ -169,27 +245,27 @@ func (br *benchmarkReceiver) SetValue(field *fast.Field) {
 	case 10504:
 		br.GroupMDEntries[br.seqIndex].OrderSide = field.Value.(string)
 	case 270:
-		br.GroupMDEntries[br.seqIndex].MDEntryPx = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].MDEntryPx = field.Value.(fast.Decimal).Float64()
 	case 271:
-		br.GroupMDEntries[br.seqIndex].MDEntrySize = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].MDEntrySize = field.Value.(fast.Decimal).Float64()
 	case 5384:
-		br.GroupMDEntries[br.seqIndex].AccruedInterestAmt = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].AccruedInterestAmt = field.Value.(fast.Decimal).Float64()
 	case 6143:
-		br.GroupMDEntries[br.seqIndex].TradeValue = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].TradeValue = field.Value.(fast.Decimal).Float64()
 	case 236:
-		br.GroupMDEntries[br.seqIndex].Yield = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].Yield = field.Value.(fast.Decimal).Float64()
 	case 64:
 		br.GroupMDEntries[br.seqIndex].SettlDate = field.Value.(uint32)
 	case 5459:
 		br.GroupMDEntries[br.seqIndex].SettleType = field.Value.(string)
 	case 44:
-		br.GroupMDEntries[br.seqIndex].Price = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].Price = field.Value.(fast.Decimal).Float64()
 	case 423:
 		br.GroupMDEntries[br.seqIndex].PriceType = field.Value.(int32)
 	case 5677:
-		br.GroupMDEntries[br.seqIndex].RepoToPx = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].RepoToPx = field.Value.(fast.Decimal).Float64()
 	case 5558:
-		br.GroupMDEntries[br.seqIndex].BuyBackPx = field.Value.(float64)
+		br.GroupMDEntries[br.seqIndex].BuyBackPx = field.Value.(fast.Decimal).Float64()
 	case 5559:
 		br.GroupMDEntries[br.seqIndex].BuyBackDate = field.Value.(uint32)
 	case 336:
@@ -201,6 +277,68 @@ func (br *benchmarkReceiver) SetValue(field *fast.Field) {
 	}
 }
 
+// benchmarkSender records decoded values and sends them to encoder without
+// conversion, so encoding does not allocate values.
+type benchmarkSender struct {
+	tid     uint
+	values  map[uint]interface{}
+	entries []map[uint]interface{}
+
+	seqLocked bool
+	seqIndex  int
+}
+
+func newBenchmarkSender() *benchmarkSender {
+	return &benchmarkSender{values: make(map[uint]interface{})}
+}
+
+func (bs *benchmarkSender) SetTemplateID(tid uint) {
+	bs.tid = tid
+}
+
+func (bs *benchmarkSender) GetTemplateID() uint {
+	return bs.tid
+}
+
+func (bs *benchmarkSender) SetLength(field *fast.Field) {
+	for i := 0; i < field.Value.(int); i++ {
+		bs.entries = append(bs.entries, make(map[uint]interface{}))
+	}
+}
+
+func (bs *benchmarkSender) GetLength(field *fast.Field) {
+	field.Value = len(bs.entries)
+}
+
+func (bs *benchmarkSender) Lock(field *fast.Field) bool {
+	bs.seqLocked = field.Name == "GroupMDEntries"
+	if bs.seqLocked {
+		bs.seqIndex = field.Value.(int)
+	}
+	return bs.seqLocked
+}
+
+func (bs *benchmarkSender) Unlock() {
+	bs.seqLocked = false
+	bs.seqIndex = 0
+}
+
+func (bs *benchmarkSender) SetValue(field *fast.Field) {
+	if bs.seqLocked {
+		bs.entries[bs.seqIndex][field.ID] = field.Value
+		return
+	}
+	bs.values[field.ID] = field.Value
+}
+
+func (bs *benchmarkSender) GetValue(field *fast.Field) {
+	if bs.seqLocked {
+		field.Value = bs.entries[bs.seqIndex][field.ID]
+		return
+	}
+	field.Value = bs.values[field.ID]
+}
+
 var (
 	decimalData1    = []byte{0xf8, 0x81, 0xfe, 0x4, 0x83, 0xff, 0xc, 0x8a, 0xfc, 0xa0, 0xff, 0x0, 0xef}
 	decimalData2    = []byte{0xf8, 0x81, 0xfe, 0x4, 0x83, 0xff, 0xc, 0x8a, 0xfc, 0xa0, 0x80}
@@ -218,6 +356,20 @@ var (
 		IndividualDecimal:    0.0032,
 		IndividualDecimalOpt: 0,
 	}
+	decimalValueMessage1 = decimalValueType{
+		TemplateID:           1,
+		CopyDecimal:          fast.Decimal{Mantissa: 515, Exponent: -2},
+		MandatoryDecimal:     decimal.New(1546, -1),
+		IndividualDecimal:    "0.0032",
+		IndividualDecimalOpt: big.NewRat(111, 10),
+	}
+	decimalNamedMessage1 = decimalNamedType{
+		TemplateID:           1,
+		CopyDecimal:          5.15,
+		MandatoryDecimal:     decimalAmount(decimal.New(1546, -1)),
+		IndividualDecimal:    "0.0032",
+		IndividualDecimalOpt: (*decimalRatio)(big.NewRat(111, 10)),
+	}
 
 	value      uint32 = 2
 	grpSegment        = struct {
@@ -271,6 +423,11 @@ var (
 		OptionalUnicode:  "klm",
 	}
 
+	stringData2    = []byte{0x80, 0x80, 0x00, 0x80, 0x80, 0x81}
+	stringMessage2 = stringType{
+		TemplateID: 4,
+	}
+
 	integerData1    = []byte{0xc0, 0x85, 0x83, 0x85, 0x25, 0x20, 0x2f, 0x47, 0xfe, 0x25, 0x20, 0x2f, 0x48, 0x80, 0x85, 0x87, 0x8, 0x23, 0x51, 0x57, 0x8d, 0x8, 0x23, 0x51, 0x57, 0x8f}
 	integerMessage1 = integerType{
 		TemplateID:      5,
@@ -284,7 +441,110 @@ var (
 		OptionalInt64:   2222222222,
 	}
 
-	groupData1    = []byte{0xe0, 0x86, 0x81, 0x82, 0x83}
+	integerData2    = []byte{0x80, 0x80, 0x81, 0x80, 0x81, 0x80, 0x81, 0x80, 0x81}
+	integerMessage2 = integerType{
+		TemplateID: 5,
+	}
+
+	tailUnicode = "abd"
+	tailUnicode4 = "axy"
+	tailData1   = []byte{0xf8, 0x87, 0x61, 0x62, 0x63, 0xe4, 0x82, 0x64, 0x83, 0x01, 0x02, 0x03}
+	tailData2   = []byte{0xb0, 0xe5, 0x80}
+	tailData3   = []byte{0x88, 0x84, 0x01, 0x02, 0x04, 0x05}
+	tailData4   = []byte{0x90, 0x83, 0x78, 0x79}
+	tailMessage1 = tailType{
+		TemplateID:  7,
+		TailAscii:   "abcd",
+		TailUnicode: &tailUnicode,
+		TailVector:  []byte{0x01, 0x02, 0x03},
+	}
+	tailMessage2 = tailType{
+		TemplateID: 7,
+		TailAscii:  "abce",
+		TailVector: []byte{0x01, 0x02, 0x03},
+	}
+	tailMessage3 = tailType{
+		TemplateID: 7,
+		TailAscii:  "abce",
+		TailVector: []byte{0x01, 0x02, 0x04, 0x05},
+	}
+	tailMessage4 = tailType{
+		TemplateID:  7,
+		TailAscii:   "abce",
+		TailUnicode: &tailUnicode4,
+		TailVector:  []byte{0x01, 0x02, 0x04, 0x05},
+	}
+
+	deltaUnicode1 = "abcde"
+	deltaUnicode3 = "abcdf"
+	deltaData1    = []byte{0xc0, 0x88, 0x80, 0x41, 0x42, 0x43, 0xc4, 0x81, 0x82, 0x64, 0x65, 0x80, 0x83, 0x01, 0x02, 0x03, 0x8a}
+	deltaData2    = []byte{0x80, 0xfe, 0xd8, 0x80, 0x81, 0x80, 0xfd}
+	deltaData3    = []byte{0x80, 0x80, 0x80, 0x82, 0x81, 0x66, 0x80, 0x80, 0x80}
+	deltaMessage1 = deltaType{
+		TemplateID:   8,
+		DeltaAscii:   "ABCD",
+		DeltaUnicode: &deltaUnicode1,
+		DeltaVector:  []byte{0x01, 0x02, 0x03},
+		DeltaUint32:  10,
+	}
+	deltaMessage2 = deltaType{
+		TemplateID:  8,
+		DeltaAscii:  "XBCD",
+		DeltaVector: []byte{0x01, 0x02},
+		DeltaUint32: 7,
+	}
+	deltaMessage3 = deltaType{
+		TemplateID:   8,
+		DeltaAscii:   "XBCD",
+		DeltaUnicode: &deltaUnicode3,
+		DeltaVector:  []byte{0x01, 0x02},
+		DeltaUint32:  7,
+	}
+
+	staticReferenceData1    = []byte{0xc0, 0x8a, 0x85, 0x61, 0xe2}
+	staticReferenceMessage1 = staticReferenceType{
+		TemplateID:   10,
+		HeaderSeqNum: 5,
+		RefData:      "ab",
+	}
+
+	dynamicReferenceData1    = []byte{0xc0, 0x8b, 0x81, 0xc0, 0x89, 0x87}
+	dynamicReferenceMessage1 = dynamicReferenceType{
+		TemplateID: 11,
+		OuterData:  1,
+		Nested: &headerType{
+			TemplateID:   9,
+			HeaderSeqNum: 7,
+		},
+	}
+
+	dictionaryData1    = []byte{0xf0, 0x8c, 0x85, 0x87}
+	dictionaryMessage1 = dictionaryAType{
+		TemplateID: 12,
+		Shared:     5,
+		Global:     7,
+	}
+	dictionaryData2    = []byte{0xe0, 0x8d, 0x86}
+	dictionaryMessage2 = dictionaryBType{
+		TemplateID:  13,
+		Shared:      6,
+		OtherGlobal: 7,
+	}
+	dictionaryData3    = []byte{0xc0, 0x8c}
+	dictionaryMessage3 = dictionaryAType{
+		TemplateID: 12,
+		Shared:     5,
+		Global:     7,
+	}
+	dictionaryData4 = []byte{0xd0, 0x8c, 0x87}
+	dictionaryData5 = []byte{0xb0, 0x85, 0x87} // template id is omitted
+	dictionaryData6 = []byte{0x90, 0x87}
+	dictionaryData7 = []byte{0x80}
+
+	resetData1    = []byte{0xc0, 0x8e}
+	resetMessage1 = resetType{TemplateID: 14}
+
+	groupData1    = []byte{0xc0, 0x86, 0x81, 0xc0, 0x82, 0x83}
 	groupMessage1 = groupType{
 		TemplateID: 6,
 		TestData:   1,
@@ -299,3 +559,80 @@ var (
 		},
 	}
 )
+
+// xmlSharedKey defines fields of different types with the same key in global dictionary.
+const xmlSharedKey = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="SharedUint" id="1">
+		<uInt32 name="Shared" id="1"><copy/></uInt32>
+	</template>
+	<template name="SharedString" id="2">
+		<string name="Shared" id="1"><copy/></string>
+	</template>
+</templates>`
+
+type (
+	sharedUintType struct {
+		TemplateID uint `fast:"*"`
+		Shared     uint32
+	}
+	sharedStringType struct {
+		TemplateID uint `fast:"*"`
+		Shared     string
+	}
+)
+
+func sharedKeyTemplates(t testing.TB) []*fast.Template {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlSharedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpls
+}
+
+// xmlTemplateIDField defines field with the same name as key of template identifier.
+const xmlTemplateIDField = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="TemplateIDField" id="1">
+		<uInt32 name="templateID" id="1"><copy/></uInt32>
+	</template>
+</templates>`
+
+type templateIDFieldType struct {
+	TemplateID uint   `fast:"*"`
+	Field      uint32 `fast:"templateID"`
+}
+
+func templateIDFieldTemplates(t testing.TB) []*fast.Template {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlTemplateIDField))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpls
+}
+
+// xmlRollback defines copy field followed by mandatory decimal.
+const xmlRollback = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Rollback" id="1">
+		<uInt32 name="A" id="1"><copy/></uInt32>
+		<decimal name="B" id="2"/>
+	</template>
+</templates>`
+
+type rollbackType struct {
+	TemplateID uint `fast:"*"`
+	A          uint32
+	B          *float64
+}
+
+func rollbackTemplates(t testing.TB) []*fast.Template {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlRollback))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpls
+}
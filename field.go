@@ -14,7 +14,7 @@ type Field struct {
 	Name  string
 	Value interface{}
 
-	index *int // message field index for reflection
+	instruction *Instruction // instruction of field for reflection
 }
 
 var fieldPool = sync.Pool{
@@ -31,6 +31,6 @@ func releaseField(field *Field) {
 	field.ID = 0
 	field.Name = ""
 	field.Value = nil
-	field.index = nil
+	field.instruction = nil
 	fieldPool.Put(field)
 }
\ No newline at end of file
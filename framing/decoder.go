@@ -0,0 +1,91 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package framing
+
+import (
+	"errors"
+	"io"
+
+	"github.com/co11ter/goFAST"
+)
+
+// DefaultMaxFrameSize is a default limit of message length defined by preamble.
+const DefaultMaxFrameSize = 1 << 20
+
+// ErrFrameSize is returned by Decoder when length of message defined by preamble
+// exceeds the limit of frame size.
+var ErrFrameSize = errors.New("framing: frame size exceeds limit")
+
+// A Decoder reads preamble and decodes FAST-encoded message from an io.Reader.
+// No thread safe!
+type Decoder struct {
+	framer  Framer
+	decoder *fast.Decoder
+	block   []byte // data of message, if preamble defines length of it
+	maxSize int    // limit of message length defined by preamble
+}
+
+// NewDecoder returns a new decoder that reads messages framed by framer from reader.
+func NewDecoder(reader io.Reader, framer Framer, tmps ...*fast.Template) *Decoder {
+	return &Decoder{
+		framer:  framer,
+		decoder: fast.NewDecoder(reader, tmps...),
+		maxSize: DefaultMaxFrameSize,
+	}
+}
+
+// SetMaxFrameSize sets limit of message length defined by preamble, the buffer
+// of message is allocated by length from the stream. Decode returns ErrFrameSize
+// if the length exceeds the limit. DefaultMaxFrameSize is used by default.
+func (d *Decoder) SetMaxFrameSize(size int) {
+	d.maxSize = size
+}
+
+// Decoder returns underlying decoder to set up it, e.g. reset dictionaries.
+func (d *Decoder) Decoder() *fast.Decoder {
+	return d.decoder
+}
+
+// Decode reads preamble and the next FAST-encoded message, it stores message in
+// the value pointed to by msg like fast.Decoder. If preamble defines length of
+// message, it returns fast.ErrBlockLength when the message does not match it and
+// ErrFrameSize when the length exceeds the limit of frame size.
+func (d *Decoder) Decode(msg interface{}) (p Preamble, err error) {
+	n, err := d.framer.ReadPreamble(d.decoder, &p)
+	if err != nil {
+		return
+	}
+
+	if p.Length == 0 {
+		err = d.decoder.Decode(msg)
+		if err == io.EOF && n > 0 {
+			err = io.ErrUnexpectedEOF // there is preamble only
+		}
+		return
+	}
+
+	if p.Length > d.maxSize {
+		err = ErrFrameSize
+		return
+	}
+
+	if cap(d.block) < p.Length {
+		d.block = make([]byte, p.Length)
+	}
+	d.block = d.block[:p.Length]
+
+	if _, err = io.ReadFull(d.decoder, d.block); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+
+	n, err = d.decoder.DecodeBytes(d.block, msg)
+	if err == nil && n != len(d.block) {
+		err = fast.ErrBlockLength
+	}
+	return
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package framing
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/co11ter/goFAST"
+)
+
+// A Encoder encodes message and writes it with preamble to io.Writer.
+// No thread safe!
+type Encoder struct {
+	framer  Framer
+	encoder *fast.Encoder
+	buf     bytes.Buffer // encoded message
+	data    []byte       // preamble and message
+	target  io.Writer
+}
+
+// NewEncoder returns a new encoder that writes messages framed by framer to writer.
+func NewEncoder(writer io.Writer, framer Framer, tmps ...*fast.Template) *Encoder {
+	e := &Encoder{
+		framer: framer,
+		target: writer,
+	}
+	e.encoder = fast.NewEncoder(&e.buf, tmps...)
+	return e
+}
+
+// Encoder returns underlying encoder to set up it, e.g. reset dictionaries.
+func (e *Encoder) Encoder() *fast.Encoder {
+	return e.encoder
+}
+
+// Encode encodes msg like fast.Encoder and writes it with preamble p by single call
+// of Write. The Length of p is set to count of bytes of encoded message. It returns
+// fast.WriteError if the data are not written completely.
+func (e *Encoder) Encode(p Preamble, msg interface{}) error {
+	e.buf.Reset()
+	if err := e.encoder.Encode(msg); err != nil {
+		return err
+	}
+
+	p.Length = e.buf.Len()
+	e.data = e.framer.AppendPreamble(e.data[:0], &p)
+	e.data = append(e.data, e.buf.Bytes()...)
+
+	n, err := e.target.Write(e.data)
+	if err == nil && n < len(e.data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return &fast.WriteError{Written: n, Size: len(e.data), Err: err}
+	}
+	return nil
+}
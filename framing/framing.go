@@ -0,0 +1,116 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package framing implements preambles, which wrap FAST messages in exchange feeds,
+// e.g. block length of FAST session control or sequence number of MOEX FAST.
+package framing
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/co11ter/goFAST"
+)
+
+// Preamble contains fields of message preamble. A framer uses only fields
+// it defines.
+type Preamble struct {
+	SeqNum uint32 // sequence number of message
+	Length int    // count of bytes of message, 0 if framer does not define it
+}
+
+// Framer reads and writes preamble of message. Custom framer has to implement it.
+type Framer interface {
+	// ReadPreamble reads preamble of the next message from r and returns count
+	// of read bytes. It returns io.EOF only if there is no more data.
+	ReadPreamble(r io.Reader, p *Preamble) (int, error)
+
+	// AppendPreamble appends preamble to dst and returns the extended buffer.
+	// The Length of p is count of bytes of encoded message.
+	AppendPreamble(dst []byte, p *Preamble) []byte
+}
+
+var (
+	// None is a framer of messages without preamble.
+	None Framer = noneFramer{}
+
+	// BlockLength is a framer of stop bit encoded uInt32 block length preamble
+	// as described in FAST session control protocol.
+	BlockLength Framer = blockLengthFramer{}
+
+	// SeqNum is a framer of 4 bytes little endian sequence number preamble,
+	// e.g. MOEX FAST.
+	SeqNum Framer = seqNumFramer{}
+)
+
+type noneFramer struct{}
+
+func (noneFramer) ReadPreamble(io.Reader, *Preamble) (int, error) {
+	return 0, nil
+}
+
+func (noneFramer) AppendPreamble(dst []byte, _ *Preamble) []byte {
+	return dst
+}
+
+type blockLengthFramer struct{}
+
+func (blockLengthFramer) ReadPreamble(r io.Reader, p *Preamble) (int, error) {
+	var b [1]byte
+	var value uint64
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			if err == io.EOF && n > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+
+		value = value<<7 | uint64(b[0]&0x7F)
+		if value > math.MaxUint32 {
+			return n + 1, fast.ErrD2
+		}
+
+		if b[0]&0x80 != 0 {
+			if value == 0 {
+				return n + 1, fast.ErrD12
+			}
+			p.Length = int(value)
+			return n + 1, nil
+		}
+	}
+}
+
+func (blockLengthFramer) AppendPreamble(dst []byte, p *Preamble) []byte {
+	var b [5]byte
+	i := len(b) - 1
+	value := uint32(p.Length)
+
+	b[i] = byte(value&0x7F) | 0x80
+	for value >>= 7; value != 0; value >>= 7 {
+		i--
+		b[i] = byte(value & 0x7F)
+	}
+	return append(dst, b[i:]...)
+}
+
+type seqNumFramer struct{}
+
+func (seqNumFramer) ReadPreamble(r io.Reader, p *Preamble) (int, error) {
+	var b [4]byte
+	n, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return n, err
+	}
+
+	p.SeqNum = binary.LittleEndian.Uint32(b[:])
+	return n, nil
+}
+
+func (seqNumFramer) AppendPreamble(dst []byte, p *Preamble) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], p.SeqNum)
+	return append(dst, b[:]...)
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package framing_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+	"github.com/co11ter/goFAST/framing"
+)
+
+type message struct {
+	TemplateID     uint   `fast:"*"`
+	MessageType    string `fast:"35"`
+	BeginString    string `fast:"8"`
+	ApplVerID      string `fast:"1128"`
+	SenderCompID   string `fast:"49"`
+	MsgSeqNum      uint32 `fast:"34"`
+	SendingTime    uint64 `fast:"52"`
+	GroupMDEntries []entry
+}
+
+type entry struct {
+	MDUpdateAction      uint32  `fast:"279"`
+	MDEntryType         string  `fast:"269"`
+	MDEntryID           string  `fast:"278"`
+	Symbol              string  `fast:"55"`
+	RptSeq              int32   `fast:"83"`
+	MDEntryDate         uint32  `fast:"272"`
+	MDEntryTime         uint32  `fast:"273"`
+	OrigTime            uint32  `fast:"9412"`
+	OrderSide           string  `fast:"10504"`
+	MDEntryPx           float64 `fast:"270"`
+	MDEntrySize         float64 `fast:"271"`
+	AccruedInterestAmt  float64 `fast:"5384"`
+	TradeValue          float64 `fast:"6143"`
+	Yield               float64 `fast:"236"`
+	SettlDate           uint32  `fast:"64"`
+	SettleType          string  `fast:"5459"`
+	Price               float64 `fast:"44"`
+	PriceType           int32   `fast:"423"`
+	RepoToPx            float64 `fast:"5677"`
+	BuyBackPx           float64 `fast:"5558"`
+	BuyBackDate         uint32  `fast:"5559"`
+	TradingSessionID    string  `fast:"336"`
+	TradingSessionSubID string  `fast:"625"`
+	RefOrderID          string  `fast:"1080"`
+}
+
+// lengthFramer is a custom framer of 2 bytes big endian length preamble.
+type lengthFramer struct{}
+
+func (lengthFramer) ReadPreamble(r io.Reader, p *framing.Preamble) (int, error) {
+	var b [2]byte
+	n, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return n, err
+	}
+	p.Length = int(binary.BigEndian.Uint16(b[:]))
+	return n, nil
+}
+
+func (lengthFramer) AppendPreamble(dst []byte, p *framing.Preamble) []byte {
+	return append(dst, byte(p.Length>>8), byte(p.Length))
+}
+
+func templates(t *testing.T) []*fast.Template {
+	ftpl, err := os.Open("../testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	tpls, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpls
+}
+
+// messages returns messages of testdata/data.dat with sequence number preamble.
+func messages(t *testing.T) (msgs []message, seqNums []uint32) {
+	file, err := os.Open("../testdata/data.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	d := framing.NewDecoder(file, framing.SeqNum, templates(t)...)
+	for {
+		var msg message
+		p, err := d.Decode(&msg)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal("can not decode", err)
+		}
+		msgs = append(msgs, msg)
+		seqNums = append(seqNums, p.SeqNum)
+	}
+}
+
+func TestSeqNumDecode(t *testing.T) {
+	msgs, seqNums := messages(t)
+	if len(msgs) == 0 {
+		t.Fatal("there are no messages")
+	}
+	if seqNums[0] != 0x16602 {
+		t.Fatalf("expected sequence number 0x16602, got %#x", seqNums[0])
+	}
+	for i, msg := range msgs {
+		if msg.MsgSeqNum != seqNums[i] {
+			t.Fatal("preamble", seqNums[i], "does not match message", msg.MsgSeqNum)
+		}
+	}
+}
+
+func TestFramers(t *testing.T) {
+	msgs, _ := messages(t)
+	msgs = msgs[:100]
+
+	framers := map[string]framing.Framer{
+		"none":         framing.None,
+		"block length": framing.BlockLength,
+		"seq num":      framing.SeqNum,
+		"custom":       lengthFramer{},
+	}
+	for name, framer := range framers {
+		var buf bytes.Buffer
+		e := framing.NewEncoder(&buf, framer, templates(t)...)
+		for i := range msgs {
+			if err := e.Encode(framing.Preamble{SeqNum: uint32(i)}, &msgs[i]); err != nil {
+				t.Fatal(name, "can not encode", err)
+			}
+		}
+
+		d := framing.NewDecoder(&buf, framer, templates(t)...)
+		for i := range msgs {
+			var msg message
+			p, err := d.Decode(&msg)
+			if err != nil {
+				t.Fatal(name, "can not decode", err)
+			}
+			if !reflect.DeepEqual(msg, msgs[i]) {
+				t.Fatal(name, "messages is not equal, got: ", msg, ", expect: ", msgs[i])
+			}
+			if framer == framing.SeqNum && p.SeqNum != uint32(i) {
+				t.Fatal(name, "expected sequence number", i, "got", p.SeqNum)
+			}
+		}
+
+		var msg message
+		if _, err := d.Decode(&msg); err != io.EOF {
+			t.Fatal(name, "expected io.EOF, got", err)
+		}
+	}
+}
+
+func TestBlockLengthErrors(t *testing.T) {
+	cases := []struct {
+		data []byte
+		err  error
+	}{
+		{data: []byte{0x80}, err: fast.ErrD12},
+		{data: []byte{0x10, 0x00, 0x00, 0x00, 0x80}, err: fast.ErrD2},
+		{data: []byte{0x01}, err: io.ErrUnexpectedEOF},
+		{data: []byte{0x82, 0xc0}, err: io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		d := framing.NewDecoder(bytes.NewReader(c.data), framing.BlockLength, templates(t)...)
+		var msg message
+		if _, err := d.Decode(&msg); !errors.Is(err, c.err) {
+			t.Fatal("expected", c.err, "got", err)
+		}
+	}
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	data := []byte{0x08, 0x80, 0x01} // block length 1024 and the part of message
+
+	d := framing.NewDecoder(bytes.NewReader(data), framing.BlockLength, templates(t)...)
+	d.SetMaxFrameSize(1023)
+
+	var msg message
+	if _, err := d.Decode(&msg); err != framing.ErrFrameSize {
+		t.Fatal("expected", framing.ErrFrameSize, "got", err)
+	}
+
+	d = framing.NewDecoder(bytes.NewReader(data), framing.BlockLength, templates(t)...)
+	if _, err := d.Decode(&msg); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected", io.ErrUnexpectedEOF, "got", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast
+
+// flusher is implemented by reflector and guards of Sender and Receiver, they keep
+// errors of message methods until the errors are flushed.
+type flusher interface {
+	flushErr() error
+}
+
+// guard keeps the first panic of Sender or Receiver method as error caused by
+// ErrPanic until it is flushed.
+type guard struct {
+	err error
+}
+
+// catch recovers panic of method, it has to be deferred by the method.
+func (g *guard) catch() {
+	if r := recover(); r != nil && g.err == nil {
+		g.err = panicError(r)
+	}
+}
+
+// flushErr returns and clears error of panic.
+func (g *guard) flushErr() error {
+	err := g.err
+	g.err = nil
+	return err
+}
+
+// senderGuard calls methods of Sender and recovers their panics.
+type senderGuard struct {
+	guard
+	sender Sender
+}
+
+func (s *senderGuard) GetTemplateID() uint {
+	defer s.catch()
+	return s.sender.GetTemplateID()
+}
+
+func (s *senderGuard) GetValue(field *Field) {
+	defer s.catch()
+	s.sender.GetValue(field)
+}
+
+func (s *senderGuard) GetLength(field *Field) {
+	defer s.catch()
+	s.sender.GetLength(field)
+}
+
+func (s *senderGuard) Lock(field *Field) bool {
+	defer s.catch()
+	return s.sender.Lock(field)
+}
+
+func (s *senderGuard) Unlock() {
+	defer s.catch()
+	s.sender.Unlock()
+}
+
+// receiverGuard calls methods of Receiver and recovers their panics.
+type receiverGuard struct {
+	guard
+	receiver Receiver
+}
+
+func (r *receiverGuard) SetTemplateID(tid uint) {
+	defer r.catch()
+	r.receiver.SetTemplateID(tid)
+}
+
+func (r *receiverGuard) SetValue(field *Field) {
+	defer r.catch()
+	r.receiver.SetValue(field)
+}
+
+func (r *receiverGuard) SetLength(field *Field) {
+	defer r.catch()
+	r.receiver.SetLength(field)
+}
+
+func (r *receiverGuard) Lock(field *Field) bool {
+	defer r.catch()
+	return r.receiver.Lock(field)
+}
+
+func (r *receiverGuard) Unlock() {
+	defer r.catch()
+	r.receiver.Unlock()
+}
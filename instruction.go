@@ -4,6 +4,13 @@
 
 package fast
 
+import (
+	"bytes"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
 // Instruction contains rules for encoding/decoding field.
 type Instruction struct {
 	ID           uint
@@ -13,23 +20,86 @@ type Instruction struct {
 	Operator     InstructionOperator
 	Instructions []*Instruction
 	Value        interface{}
+	Dictionary   string
+	Key          string
+	TypeRef      string
+	Elements     []string      // names of enum or set elements
+	Unit         time.Duration // unit of timestamp or time, millisecond if it is zero
 
 	pMapSize int
-	key   string
+	dict     string
+	key      string
+	slot     int // index of instruction in plans of reflection
 }
 
+// isValid returns true if operator is applicable to type of instruction.
 func (i *Instruction) isValid() bool {
-	if i.Operator == OperatorDelta && (i.Type < TypeUint32 || i.Type > TypeMantissa) {
-		return false
+	switch i.Operator {
+	case OperatorNone:
+		return true
+	case OperatorIncrement:
+		return i.Type >= TypeUint32 && i.Type <= TypeMantissa
+	case OperatorTail:
+		return i.Type >= TypeASCIIString && i.Type <= TypeByteVector
+	case OperatorDelta:
+		return i.Type >= TypeUint32 && i.Type <= TypeByteVector
 	}
+	return i.Type >= TypeUint32 && i.Type <= TypeByteVector || i.Type >= TypeBoolean && i.Type <= TypeSet
+}
 
-	if i.Operator == OperatorTail && (i.Type < TypeASCIIString || i.Type > TypeByteVector) {
+// isValidValue returns true if type of initial value matches type of instruction.
+func (i *Instruction) isValidValue() bool {
+	if !i.hasType(i.Value) {
 		return false
 	}
 
+	switch i.Type {
+	case TypeEnum:
+		return indexOf(i.Elements, i.Value.(string)) >= 0
+	case TypeSet:
+		_, known := i.setBits(i.Value.([]string))
+		return known
+	}
 	return true
 }
 
+// hasType returns true if value has Go type of instruction values.
+func (i *Instruction) hasType(value interface{}) (ok bool) {
+	switch i.Type {
+	case TypeUint32, TypeLength:
+		_, ok = value.(uint32)
+	case TypeInt32, TypeExponent:
+		_, ok = value.(int32)
+	case TypeUint64, TypeBitGroup:
+		_, ok = value.(uint64)
+	case TypeInt64, TypeMantissa:
+		_, ok = value.(int64)
+	case TypeDecimal:
+		_, ok = value.(Decimal)
+	case TypeASCIIString, TypeUnicodeString, TypeEnum:
+		_, ok = value.(string)
+	case TypeByteVector:
+		_, ok = value.([]byte)
+	case TypeBoolean:
+		_, ok = value.(bool)
+	case TypeSet:
+		_, ok = value.([]string)
+	case TypeTimestamp, TypeTime, TypeDate:
+		_, ok = value.(time.Time)
+	}
+	return
+}
+
+// previous returns previous value of instruction from dictionary. It returns ErrD4
+// if the value has another type, e.g. the entry is shared by fields of different types.
+func (i *Instruction) previous(s storage) (interface{}, bool, error) {
+	value, ok := s.lookup(i.dict, i.key)
+	if value != nil && !i.hasType(value) {
+		return nil, ok, ErrD4
+	}
+	return value, ok, nil
+}
+
 func (i *Instruction) isOptional() bool {
 	return i.Presence == PresenceOptional
 }
@@ -44,6 +114,23 @@ func (i *Instruction) hasPmapBit() bool {
 
 func (i *Instruction) inject(writer *writer, s storage, pmap *pMap, value interface{}) (err error) {
 
+	if _, ok := value.(Decimal); !ok && i.Type == TypeDecimal && value != nil {
+		if value, err = toDecimal(value); err != nil {
+			return err
+		}
+	}
+
+	if value != nil {
+		if value, err = i.castValue(value); err != nil {
+			return err
+		}
+	}
+
+	// null of mandatory decimal can not be encoded
+	if value == nil && i.Type == TypeDecimal && !i.isOptional() && i.Operator != OperatorConstant {
+		return ErrMissingValue
+	}
+
 	if i.Type == TypeDecimal && len(i.Instructions) > 0 {
 		return i.injectDecimal(writer, s, pmap, value)
 	}
@@ -54,16 +141,16 @@ func (i *Instruction) inject(writer *writer, s storage, pmap *pMap, value interf
 		if err != nil {
 			return
 		}
-		s.save(i.key, value)
+		s.save(i.dict, i.key, value)
 	case OperatorConstant:
 		if i.isOptional() {
 			pmap.SetNextBit(value != nil)
 		}
-		s.save(i.key, value)
+		s.save(i.dict, i.key, value)
 	case OperatorDefault:
-		if i.Value == value {
+		if i.equal(i.Value, value) {
 			pmap.SetNextBit(false)
-			s.save(i.key, value)
+			s.save(i.dict, i.key, value)
 			return
 		}
 		pmap.SetNextBit(true)
@@ -72,36 +159,14 @@ func (i *Instruction) inject(writer *writer, s storage, pmap *pMap, value interf
 			return
 		}
 		if value != nil {
-			s.save(i.key, value)
+			s.save(i.dict, i.key, value)
 		}
 	case OperatorDelta:
-		if previous := s.load(i.key); previous != nil {
-			value = delta(value, previous)
-		}
-		err = i.write(writer, value)
-		if err != nil {
-			return
-		}
-		s.save(i.key, value)
+		err = i.injectDelta(writer, s, value)
 	case OperatorTail:
-		// TODO
+		err = i.injectTail(writer, s, pmap, value)
 	case OperatorCopy, OperatorIncrement:
-		previous := s.load(i.key)
-		s.save(i.key, value)
-		if previous == nil {
-			if i.Value == value {
-				pmap.SetNextBit(false)
-				return
-			}
-		} else if isEmpty(previous) {
-			if value == nil {
-				pmap.SetNextBit(false)
-				return
-			}
-		}
-
-		pmap.SetNextBit(true)
-		err = i.write(writer, value)
+		err = i.injectCopy(writer, s, pmap, value)
 	}
 	return err
 }
@@ -116,24 +181,29 @@ func (i *Instruction) write(writer *writer, value interface{}) (err error) {
 	case TypeByteVector:
 		err = writer.WriteByteVector(i.isNullable(), value.([]byte))
 	case TypeUint32, TypeLength:
-		err = writer.WriteUint(i.isNullable(), uint64(value.(uint32)), maxSize32)
+		err = writer.WriteUint(i.isNullable(), uint64(value.(uint32)))
 	case TypeUint64:
-		err = writer.WriteUint(i.isNullable(), value.(uint64), maxSize64)
+		err = writer.WriteUint(i.isNullable(), value.(uint64))
 	case TypeASCIIString:
 		err = writer.WriteString(i.isNullable(), value.(string))
 	case TypeUnicodeString:
-		err = writer.WriteByteVector(i.isNullable(), []byte(value.(string)))
+		err = writer.WriteUnicodeString(i.isNullable(), value.(string))
 	case TypeInt64, TypeMantissa:
-		err = writer.WriteInt(i.isNullable(), value.(int64), maxSize64)
+		err = writer.WriteInt(i.isNullable(), value.(int64))
 	case TypeInt32, TypeExponent:
-		err = writer.WriteInt(i.isNullable(), int64(value.(int32)), maxSize32)
+		err = writer.WriteInt(i.isNullable(), int64(value.(int32)))
 	case TypeDecimal:
-		mantissa, exponent := newMantExp(value.(float64))
-		err = writer.WriteInt(i.isNullable(), int64(exponent), maxSize32)
+		d := value.(Decimal)
+		if !d.isValid() {
+			return ErrR1
+		}
+		err = writer.WriteInt(i.isNullable(), int64(d.Exponent))
 		if err != nil {
 			return
 		}
-		err = writer.WriteInt(false, mantissa, maxSize64)
+		err = writer.WriteInt(false, d.Mantissa)
+	default:
+		err = i.writeExtension(writer, value)
 	}
 	return
 }
@@ -150,7 +220,7 @@ func (i *Instruction) extract(reader *reader, s storage, pmap *pMap) (result int
 		if err != nil {
 			return nil, err
 		}
-		s.save(i.key, result)
+		s.save(i.dict, i.key, result)
 	case OperatorConstant:
 		if i.isOptional() {
 			if pmap.IsNextBitSet() {
@@ -159,46 +229,20 @@ func (i *Instruction) extract(reader *reader, s storage, pmap *pMap) (result int
 		} else {
 			result = i.Value
 		}
-		s.save(i.key, result)
+		s.save(i.dict, i.key, result)
 	case OperatorDefault:
 		if pmap.IsNextBitSet() {
 			result, err = i.read(reader)
 		} else {
 			result = i.Value
-			s.save(i.key, result)
+			s.save(i.dict, i.key, result)
 		}
 	case OperatorDelta:
-		result, err = i.read(reader)
-		if err != nil {
-			return nil, err
-		}
-		if previous := s.load(i.key); previous != nil {
-			result = sum(result, previous)
-		}
-		s.save(i.key, result)
+		result, err = i.extractDelta(reader, s)
 	case OperatorTail:
-		// TODO
+		result, err = i.extractTail(reader, s, pmap)
 	case OperatorCopy, OperatorIncrement:
-		if pmap.IsNextBitSet() {
-			result, err = i.read(reader)
-			if err != nil {
-				return nil, err
-			}
-			s.save(i.key, result)
-		} else {
-			if s.load(i.key) == nil {
-				result = i.Value
-				s.save(i.key, result)
-			} else {
-				// TODO what have to do on empty value
-
-				result = s.load(i.key)
-				if i.Operator == OperatorIncrement {
-					result = increment(result)
-					s.save(i.key, result)
-				}
-			}
-		}
+		result, err = i.extractCopy(reader, s, pmap)
 	}
 
 	return
@@ -219,6 +263,9 @@ func (i *Instruction) read(reader *reader) (result interface{}, err error) {
 		if err != nil {
 			return result, err
 		}
+		if tmp != nil && reader.strict && *tmp > math.MaxUint32 {
+			return result, ErrD2
+		}
 		if tmp != nil {
 			result = uint32(*tmp)
 		}
@@ -259,6 +306,9 @@ func (i *Instruction) read(reader *reader) (result interface{}, err error) {
 		if err != nil {
 			return result, err
 		}
+		if tmp != nil && reader.strict && (*tmp > math.MaxInt32 || *tmp < math.MinInt32) {
+			return result, ErrD2
+		}
 		if tmp != nil {
 			result = int32(*tmp)
 		}
@@ -269,30 +319,44 @@ func (i *Instruction) read(reader *reader) (result interface{}, err error) {
 		}
 		if tmp != nil {
 			exponent := int32(*tmp)
+			if exponent < minExponent || exponent > maxExponent {
+				return result, ErrR1
+			}
 			mantissa, err := reader.ReadInt(false)
 			if err != nil {
 				return result, err
 			}
-			result = newFloat(*mantissa, exponent)
+			result = Decimal{Mantissa: *mantissa, Exponent: exponent}
 		}
+	default:
+		return i.readExtension(reader)
 	}
 
 	return result, err
 }
 
 func (i *Instruction) injectDecimal(writer *writer, s storage, pmap *pMap, value interface{}) (err error) {
-	mantissa, exponent := newMantExp(value.(float64))
+	// absent decimal is encoded by absent exponent only
+	var mantissa, exponent interface{}
+	if value != nil {
+		d := value.(Decimal)
+		if !d.isValid() {
+			return ErrR1
+		}
+		mantissa, exponent = d.Mantissa, d.Exponent
+	}
+
 	for _, in := range i.Instructions {
-		if in.Type == TypeMantissa {
+		if in.Type == TypeMantissa && value != nil {
 			err = in.inject(writer, s, pmap, mantissa)
 			if err != nil {
-				return
+				return wrapError(err, tagMantissa, in.Operator, 0)
 			}
 		}
 		if in.Type == TypeExponent {
 			err = in.inject(writer, s, pmap, exponent)
 			if err != nil {
-				return
+				return wrapError(err, tagExponent, in.Operator, 0)
 			}
 		}
 	}
@@ -307,43 +371,467 @@ func (i *Instruction) extractDecimal(reader *reader, s storage, pmap *pMap) (int
 		if in.Type == TypeMantissa {
 			mField, err := in.extract(reader, s, pmap)
 			if err != nil {
-				return nil, err
+				return nil, wrapError(err, tagMantissa, in.Operator, reader.offset)
 			}
 			mantissa = mField.(int64)
 		}
 		if in.Type == TypeExponent {
 			eField, err := in.extract(reader, s, pmap)
-			if err != nil || eField == nil {
-				return nil, err
+			if err != nil {
+				return nil, wrapError(err, tagExponent, in.Operator, reader.offset)
+			}
+			if eField == nil {
+				return nil, nil
 			}
 			exponent = eField.(int32)
+			if exponent < minExponent || exponent > maxExponent {
+				return nil, ErrR1
+			}
 		}
 	}
 
-	return newFloat(mantissa, exponent), nil
+	return Decimal{Mantissa: mantissa, Exponent: exponent}, nil
 }
 
-func isEmpty(value interface{}) bool {
+// injectCopy encodes value for copy and increment operators.
+func (i *Instruction) injectCopy(writer *writer, s storage, pmap *pMap, value interface{}) error {
+	previous, ok, err := i.previous(s)
+	if err != nil {
+		return err
+	}
+	s.save(i.dict, i.key, value)
+
+	switch {
+	case !ok:
+		if i.equal(i.Value, value) {
+			pmap.SetNextBit(false)
+			return nil
+		}
+	case previous == nil:
+		if value == nil {
+			pmap.SetNextBit(false)
+			return nil
+		}
+	case i.Operator == OperatorIncrement:
+		if value != nil && i.equal(increment(previous), value) {
+			pmap.SetNextBit(false)
+			return nil
+		}
+	default:
+		if i.equal(previous, value) {
+			pmap.SetNextBit(false)
+			return nil
+		}
+	}
+
+	pmap.SetNextBit(true)
+	return i.write(writer, value)
+}
+
+// extractCopy decodes value for copy and increment operators.
+func (i *Instruction) extractCopy(reader *reader, s storage, pmap *pMap) (interface{}, error) {
+	if pmap.IsNextBitSet() {
+		result, err := i.read(reader)
+		if err != nil {
+			return nil, err
+		}
+		s.save(i.dict, i.key, result)
+		return result, nil
+	}
+
+	previous, ok, err := i.previous(s)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !ok:
+		if i.Value == nil && !i.isOptional() {
+			return nil, ErrD5
+		}
+		s.save(i.dict, i.key, i.Value)
+		return i.Value, nil
+	case previous == nil:
+		if !i.isOptional() {
+			return nil, ErrD6
+		}
+		return nil, nil
+	case i.Operator == OperatorIncrement:
+		previous = increment(previous)
+		s.save(i.dict, i.key, previous)
+	}
+	return previous, nil
+}
+
+func (i *Instruction) injectDelta(writer *writer, s storage, value interface{}) error {
+	if value == nil {
+		if !i.isOptional() {
+			return ErrMissingValue
+		}
+		return writer.WriteNil()
+	}
+
+	base, err := i.deltaBase(s)
+	if err != nil {
+		return err
+	}
+
+	switch i.Type {
+	case TypeASCIIString, TypeUnicodeString, TypeByteVector:
+		length, diff := deltaOf(i.toBytes(base), i.toBytes(value))
+		err = writer.WriteInt(i.isNullable(), length)
+		if err != nil {
+			return err
+		}
+		if i.Type == TypeASCIIString {
+			err = writer.WriteString(false, string(diff))
+		} else {
+			err = writer.WriteByteVector(false, diff)
+		}
+		if vector, ok := value.([]byte); ok {
+			value = append([]byte{}, vector...) // dictionary must not share memory with message
+		}
+	case TypeDecimal:
+		// exponent and mantissa are encoded as separate deltas
+		d, b := value.(Decimal), base.(Decimal)
+		if !d.isValid() {
+			return ErrR1
+		}
+		err = writer.WriteInt(i.isNullable(), int64(d.Exponent-b.Exponent))
+		if err != nil {
+			return err
+		}
+		err = writer.WriteInt(false, d.Mantissa-b.Mantissa)
+	default:
+		err = writer.WriteInt(i.isNullable(), delta(value, base))
+	}
+
+	if err != nil {
+		return err
+	}
+	s.save(i.dict, i.key, value)
+	return nil
+}
+
+func (i *Instruction) extractDelta(reader *reader, s storage) (interface{}, error) {
+	tmp, err := reader.ReadInt(i.isNullable())
+	if err != nil || tmp == nil {
+		return nil, err
+	}
+	value := *tmp
+
+	var result interface{}
+	switch i.Type {
+	case TypeASCIIString, TypeUnicodeString, TypeByteVector:
+		if value > math.MaxInt32 || value < math.MinInt32 {
+			return nil, ErrD7
+		}
+
+		var diff []byte
+		if i.Type == TypeASCIIString {
+			str, err := reader.ReadString(false)
+			if err != nil {
+				return nil, err
+			}
+			diff = []byte(*str)
+		} else {
+			vector, err := reader.ReadByteVector(false)
+			if err != nil {
+				return nil, err
+			}
+			diff = *vector
+		}
+
+		base, err := i.deltaBase(s)
+		if err != nil {
+			return nil, err
+		}
+
+		combined, err := applyDelta(i.toBytes(base), value, diff)
+		if err != nil {
+			return nil, err
+		}
+
+		if i.Type == TypeUnicodeString && !utf8.Valid(combined) {
+			return nil, ErrR2
+		}
+		result = i.fromBytes(combined)
+	case TypeDecimal:
+		mantissa, err := reader.ReadInt(false)
+		if err != nil {
+			return nil, err
+		}
+
+		base, err := i.deltaBase(s)
+		if err != nil {
+			return nil, err
+		}
+
+		d := base.(Decimal)
+		exponent := int64(d.Exponent) + value
+		if exponent < minExponent || exponent > maxExponent {
+			return nil, ErrR1
+		}
+		result = Decimal{Mantissa: d.Mantissa + *mantissa, Exponent: int32(exponent)}
+	default:
+		base, err := i.deltaBase(s)
+		if err != nil {
+			return nil, err
+		}
+		result = sum(base, value)
+	}
+
+	s.save(i.dict, i.key, result)
+	return result, nil
+}
+
+// deltaBase returns base value for delta operator.
+func (i *Instruction) deltaBase(s storage) (interface{}, error) {
+	previous, ok, err := i.previous(s)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if previous == nil {
+			return nil, ErrD6
+		}
+		return previous, nil
+	}
+
+	if i.Value != nil {
+		return i.Value, nil
+	}
+
+	switch i.Type {
+	case TypeUint32, TypeLength:
+		return uint32(0), nil
+	case TypeUint64:
+		return uint64(0), nil
+	case TypeInt32, TypeExponent:
+		return int32(0), nil
+	case TypeInt64, TypeMantissa:
+		return int64(0), nil
+	case TypeDecimal:
+		return Decimal{}, nil
+	}
+	return i.fromBytes(nil), nil
+}
+
+func (i *Instruction) injectTail(writer *writer, s storage, pmap *pMap, value interface{}) error {
+	previous, ok, err := i.previous(s)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		if !i.isOptional() {
+			value = i.fromBytes(nil)
+		} else {
+			s.save(i.dict, i.key, nil)
+			// decoder gets absent value from empty previous value or undefined one without initial value
+			if (ok && previous == nil) || (!ok && i.Value == nil) {
+				pmap.SetNextBit(false)
+				return nil
+			}
+			pmap.SetNextBit(true)
+			return writer.WriteNil()
+		}
+	}
+
+	if vector, isVector := value.([]byte); isVector {
+		value = append([]byte{}, vector...) // dictionary must not share memory with message
+	}
+
+	if ok && previous != nil && i.equal(previous, value) {
+		pmap.SetNextBit(false)
+		return nil
+	}
+
+	if !ok && i.Value != nil && i.equal(i.Value, value) {
+		pmap.SetNextBit(false)
+		s.save(i.dict, i.key, value)
+		return nil
+	}
+
+	base := previous
+	if base == nil {
+		base = i.Value
+	}
+
+	tail, valid := tailOf(i.toBytes(base), i.toBytes(value))
+	if !valid {
+		return ErrTailLength
+	}
+
+	pmap.SetNextBit(true)
+	s.save(i.dict, i.key, value)
+	return i.write(writer, i.fromBytes(tail))
+}
+
+func (i *Instruction) extractTail(reader *reader, s storage, pmap *pMap) (interface{}, error) {
+	if pmap.IsNextBitSet() {
+		tail, err := i.read(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if tail == nil {
+			s.save(i.dict, i.key, nil)
+			return nil, nil
+		}
+
+		base, _, err := i.previous(s)
+		if err != nil {
+			return nil, err
+		}
+		if base == nil {
+			base = i.Value
+		}
+
+		result := applyTail(i.toBytes(base), i.toBytes(tail))
+		if i.Type == TypeUnicodeString && !utf8.Valid(result) {
+			return nil, ErrR2
+		}
+
+		s.save(i.dict, i.key, i.fromBytes(result))
+		return i.fromBytes(result), nil
+	}
+
+	previous, ok, err := i.previous(s)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if i.Value == nil && !i.isOptional() {
+			return nil, ErrD5
+		}
+		s.save(i.dict, i.key, i.Value)
+		return i.Value, nil
+	}
+
+	if previous == nil && !i.isOptional() {
+		return nil, ErrD6
+	}
+	return previous, nil
+}
+
+// toBytes returns string or byte vector value as slice of bytes.
+func (i *Instruction) toBytes(value interface{}) []byte {
 	switch value.(type) {
-	case int64:
-		return value.(int64) == 0
-	case int32:
-		return value.(int32) == 0
-	case uint64:
-		return value.(uint64) == 0
-	case uint32:
-		return value.(uint32) == 0
-	case int:
-		return value.(int) == 0
-	case uint:
-		return value.(uint) == 0
 	case string:
-		return value.(string) == ""
+		return []byte(value.(string))
+	case []byte:
+		return value.([]byte)
 	}
-	return true
+	return nil
+}
+
+// fromBytes converts slice of bytes to value of instruction type.
+func (i *Instruction) fromBytes(data []byte) interface{} {
+	if i.Type == TypeByteVector {
+		if data == nil {
+			return []byte{}
+		}
+		return data
+	}
+	return string(data)
+}
+
+func (i *Instruction) equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch i.Type {
+	case TypeASCIIString, TypeUnicodeString, TypeByteVector:
+		return bytes.Equal(i.toBytes(a), i.toBytes(b))
+	case TypeDecimal:
+		return a.(Decimal).Equal(b.(Decimal))
+	case TypeSet:
+		aSet, _ := a.([]string)
+		bSet, _ := b.([]string)
+		aBits, aOK := i.setBits(aSet)
+		bBits, bOK := i.setBits(bSet)
+		return aOK && bOK && aBits == bBits
+	case TypeTimestamp, TypeTime, TypeDate:
+		aTime, aOK := a.(time.Time)
+		bTime, bOK := b.(time.Time)
+		return aOK && bOK && aTime.Equal(bTime)
+	}
+	return a == b
+}
+
+// deltaOf returns subtraction length and difference which transform base into value.
+// Negative subtraction length means the difference have to be prepended to base.
+func deltaOf(base, value []byte) (int64, []byte) {
+	prefix := 0
+	for prefix < len(base) && prefix < len(value) && base[prefix] == value[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(base) && suffix < len(value) &&
+		base[len(base)-1-suffix] == value[len(value)-1-suffix] {
+		suffix++
+	}
+
+	if suffix > prefix {
+		// excess-1 encoding makes possible to prepend without removing
+		return -int64(len(base)-suffix) - 1, value[:len(value)-suffix]
+	}
+	return int64(len(base) - prefix), value[prefix:]
+}
+
+// applyDelta removes length bytes from the end of base and appends diff, or removes
+// them from the front and prepends diff if length is negative.
+func applyDelta(base []byte, length int64, diff []byte) ([]byte, error) {
+	front := length < 0
+	if front {
+		length = -length - 1
+	}
+
+	if length > int64(len(base)) {
+		return nil, ErrD7
+	}
+
+	res := make([]byte, 0, len(base)-int(length)+len(diff))
+	if front {
+		res = append(res, diff...)
+		return append(res, base[length:]...), nil
+	}
+	res = append(res, base[:len(base)-int(length)]...)
+	return append(res, diff...), nil
+}
+
+// tailOf returns tail which replaces the end of base to get value.
+// It returns false if value can not be combined from base by tail.
+func tailOf(base, value []byte) ([]byte, bool) {
+	if len(value) > len(base) {
+		return value, true
+	}
+
+	if len(value) < len(base) {
+		return nil, false
+	}
+
+	index := 0
+	for index < len(value) && value[index] == base[index] {
+		index++
+	}
+	return value[index:], true
+}
+
+// applyTail replaces the end of base by tail and returns new value.
+func applyTail(base, tail []byte) []byte {
+	if len(tail) >= len(base) {
+		return append([]byte{}, tail...)
+	}
+
+	res := make([]byte, len(base))
+	copy(res, base[:len(base)-len(tail)])
+	copy(res[len(base)-len(tail):], tail)
+	return res
 }
 
-// TODO need implements for string
 func sum(values ...interface{}) (res interface{}) {
 	switch values[0].(type) {
 	case int64:
@@ -358,19 +846,12 @@ func sum(values ...interface{}) (res interface{}) {
 	return
 }
 
-// TODO need implements for string
-func delta(values ...interface{}) (res interface{}) {
-	switch values[0].(type) {
-	case int64:
-		res = values[0].(int64) - int64(toInt(values[1]))
-	case int32:
-		res = values[0].(int32) - int32(toInt(values[1]))
-	case uint64:
-		res = values[0].(uint64) - uint64(toInt(values[1]))
-	case uint32:
-		res = values[0].(uint32) - uint32(toInt(values[1]))
+// delta returns signed difference between integer value and base.
+func delta(value, base interface{}) int64 {
+	if v, ok := value.(uint64); ok {
+		return int64(v - uint64(toInt(base)))
 	}
-	return
+	return int64(toInt(value) - toInt(base))
 }
 
 func toInt(value interface{}) int {
@@ -0,0 +1,9 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package generated contains types generated by fastgen from testdata/test.xml.
+// It is used to test generated code against reflection.
+package generated
+
+//go:generate go run ../../cmd/fastgen -template ../../testdata/test.xml -package generated -output messages.go
@@ -0,0 +1,1459 @@
+// Code generated by fastgen from test.xml. DO NOT EDIT.
+
+package generated
+
+import "github.com/co11ter/goFAST"
+
+// segment is a message or nested struct of group or sequence element.
+type segment interface {
+	setTemplateID(uint)
+	templateID() uint
+	setValue(*fast.Field)
+	getValue(*fast.Field)
+	setLength(*fast.Field)
+	getLength(*fast.Field)
+	lock(*fast.Field) segment
+}
+
+// lockState is a stack of locked segments of message.
+type lockState struct {
+	segments [2]segment
+	depth    int
+	generic  int // count of locks inside generic message of template reference
+}
+
+func (s *lockState) active(root segment) segment {
+	if s.depth == 0 {
+		return root
+	}
+	return s.segments[s.depth-1]
+}
+
+func (s *lockState) lock(root segment, field *fast.Field) bool {
+	active := s.active(root)
+	if g, ok := active.(generic); ok {
+		if !g.msg.Lock(field) {
+			return false
+		}
+		s.generic++
+		return true
+	}
+
+	next := active.lock(field)
+	if next == nil {
+		return false
+	}
+	s.segments[s.depth] = next
+	s.depth++
+	return true
+}
+
+func (s *lockState) unlock() {
+	if s.generic > 0 {
+		s.generic--
+		s.segments[s.depth-1].(generic).msg.Unlock()
+		return
+	}
+	s.depth--
+	s.segments[s.depth] = nil
+}
+
+// generic is a segment of dynamic template reference.
+type generic struct {
+	msg *fast.Message
+}
+
+func (g generic) setTemplateID(tid uint)      { g.msg.SetTemplateID(tid) }
+func (g generic) templateID() uint            { return g.msg.GetTemplateID() }
+func (g generic) setValue(field *fast.Field)  { g.msg.SetValue(field) }
+func (g generic) getValue(field *fast.Field)  { g.msg.GetValue(field) }
+func (g generic) setLength(field *fast.Field) { g.msg.SetLength(field) }
+func (g generic) getLength(field *fast.Field) { g.msg.GetLength(field) }
+func (g generic) lock(*fast.Field) segment    { return nil }
+
+// Decimal is a message of template 1.
+type Decimal struct {
+	TemplateID           uint          `fast:"*"`
+	CopyDecimal          *fast.Decimal `fast:"1"`
+	MandatoryDecimal     fast.Decimal  `fast:"2"`
+	IndividualDecimal    fast.Decimal  `fast:"3"`
+	IndividualDecimalOpt *fast.Decimal `fast:"4"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Decimal) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Decimal) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Decimal) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Decimal) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Decimal) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Decimal) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Decimal) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Decimal) Unlock() { m.state.unlock() }
+
+func (m *Decimal) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Decimal) templateID() uint { return 1 }
+
+func (m *Decimal) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		v := field.Value.(fast.Decimal)
+		m.CopyDecimal = &v
+	case 2:
+		m.MandatoryDecimal = field.Value.(fast.Decimal)
+	case 3:
+		m.IndividualDecimal = field.Value.(fast.Decimal)
+	case 4:
+		v := field.Value.(fast.Decimal)
+		m.IndividualDecimalOpt = &v
+	}
+}
+
+func (m *Decimal) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		if m.CopyDecimal != nil {
+			field.Value = *m.CopyDecimal
+		}
+	case 2:
+		field.Value = m.MandatoryDecimal
+	case 3:
+		field.Value = m.IndividualDecimal
+	case 4:
+		if m.IndividualDecimalOpt != nil {
+			field.Value = *m.IndividualDecimalOpt
+		}
+	}
+}
+
+func (m *Decimal) setLength(field *fast.Field) {
+}
+
+func (m *Decimal) getLength(field *fast.Field) {
+}
+
+func (m *Decimal) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Sequence is a message of template 2.
+type Sequence struct {
+	TemplateID        uint                        `fast:"*"`
+	TestData          uint32                      `fast:"1"`
+	OuterSequence     []SequenceOuterSequence     `fast:"OuterSequence"`
+	NextOuterSequence []SequenceNextOuterSequence `fast:"NextOuterSequence"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Sequence) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Sequence) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Sequence) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Sequence) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Sequence) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Sequence) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Sequence) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Sequence) Unlock() { m.state.unlock() }
+
+func (m *Sequence) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Sequence) templateID() uint { return 2 }
+
+func (m *Sequence) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.TestData = field.Value.(uint32)
+	}
+}
+
+func (m *Sequence) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.TestData
+	}
+}
+
+func (m *Sequence) setLength(field *fast.Field) {
+	switch field.Name {
+	case "OuterSequence":
+		m.OuterSequence = make([]SequenceOuterSequence, field.Value.(int))
+	case "NextOuterSequence":
+		m.NextOuterSequence = make([]SequenceNextOuterSequence, field.Value.(int))
+	}
+}
+
+func (m *Sequence) getLength(field *fast.Field) {
+	switch field.Name {
+	case "OuterSequence":
+		field.Value = len(m.OuterSequence)
+	case "NextOuterSequence":
+		field.Value = len(m.NextOuterSequence)
+	}
+}
+
+func (m *Sequence) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "OuterSequence":
+		return &m.OuterSequence[field.Value.(int)]
+	case "NextOuterSequence":
+		return &m.NextOuterSequence[field.Value.(int)]
+	}
+	return nil
+}
+
+// SequenceOuterSequence is an element of sequence OuterSequence of Sequence.
+type SequenceOuterSequence struct {
+	OuterTestData uint32                               `fast:"3"`
+	InnerSequence []SequenceOuterSequenceInnerSequence `fast:"InnerSequence"`
+}
+
+func (m *SequenceOuterSequence) setTemplateID(uint) {}
+
+func (m *SequenceOuterSequence) templateID() uint { return 0 }
+
+func (m *SequenceOuterSequence) setValue(field *fast.Field) {
+	switch field.ID {
+	case 3:
+		m.OuterTestData = field.Value.(uint32)
+	}
+}
+
+func (m *SequenceOuterSequence) getValue(field *fast.Field) {
+	switch field.ID {
+	case 3:
+		field.Value = m.OuterTestData
+	}
+}
+
+func (m *SequenceOuterSequence) setLength(field *fast.Field) {
+	switch field.Name {
+	case "InnerSequence":
+		m.InnerSequence = make([]SequenceOuterSequenceInnerSequence, field.Value.(int))
+	}
+}
+
+func (m *SequenceOuterSequence) getLength(field *fast.Field) {
+	switch field.Name {
+	case "InnerSequence":
+		field.Value = len(m.InnerSequence)
+	}
+}
+
+func (m *SequenceOuterSequence) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "InnerSequence":
+		return &m.InnerSequence[field.Value.(int)]
+	}
+	return nil
+}
+
+// SequenceOuterSequenceInnerSequence is an element of sequence InnerSequence of SequenceOuterSequence.
+type SequenceOuterSequenceInnerSequence struct {
+	InnerTestData uint32 `fast:"5"`
+}
+
+func (m *SequenceOuterSequenceInnerSequence) setTemplateID(uint) {}
+
+func (m *SequenceOuterSequenceInnerSequence) templateID() uint { return 0 }
+
+func (m *SequenceOuterSequenceInnerSequence) setValue(field *fast.Field) {
+	switch field.ID {
+	case 5:
+		m.InnerTestData = field.Value.(uint32)
+	}
+}
+
+func (m *SequenceOuterSequenceInnerSequence) getValue(field *fast.Field) {
+	switch field.ID {
+	case 5:
+		field.Value = m.InnerTestData
+	}
+}
+
+func (m *SequenceOuterSequenceInnerSequence) setLength(field *fast.Field) {
+}
+
+func (m *SequenceOuterSequenceInnerSequence) getLength(field *fast.Field) {
+}
+
+func (m *SequenceOuterSequenceInnerSequence) lock(field *fast.Field) segment {
+	return nil
+}
+
+// SequenceNextOuterSequence is an element of sequence NextOuterSequence of Sequence.
+type SequenceNextOuterSequence struct {
+	NextOuterTestData uint32 `fast:"7"`
+}
+
+func (m *SequenceNextOuterSequence) setTemplateID(uint) {}
+
+func (m *SequenceNextOuterSequence) templateID() uint { return 0 }
+
+func (m *SequenceNextOuterSequence) setValue(field *fast.Field) {
+	switch field.ID {
+	case 7:
+		m.NextOuterTestData = field.Value.(uint32)
+	}
+}
+
+func (m *SequenceNextOuterSequence) getValue(field *fast.Field) {
+	switch field.ID {
+	case 7:
+		field.Value = m.NextOuterTestData
+	}
+}
+
+func (m *SequenceNextOuterSequence) setLength(field *fast.Field) {
+}
+
+func (m *SequenceNextOuterSequence) getLength(field *fast.Field) {
+}
+
+func (m *SequenceNextOuterSequence) lock(field *fast.Field) segment {
+	return nil
+}
+
+// ByteVector is a message of template 3.
+type ByteVector struct {
+	TemplateID      uint   `fast:"*"`
+	MandatoryVector []byte `fast:"1"`
+	OptionalVector  []byte `fast:"2"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *ByteVector) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *ByteVector) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *ByteVector) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *ByteVector) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *ByteVector) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *ByteVector) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *ByteVector) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *ByteVector) Unlock() { m.state.unlock() }
+
+func (m *ByteVector) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *ByteVector) templateID() uint { return 3 }
+
+func (m *ByteVector) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.MandatoryVector = field.Value.([]byte)
+	case 2:
+		m.OptionalVector = field.Value.([]byte)
+	}
+}
+
+func (m *ByteVector) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.MandatoryVector
+	case 2:
+		if m.OptionalVector != nil {
+			field.Value = m.OptionalVector
+		}
+	}
+}
+
+func (m *ByteVector) setLength(field *fast.Field) {
+}
+
+func (m *ByteVector) getLength(field *fast.Field) {
+}
+
+func (m *ByteVector) lock(field *fast.Field) segment {
+	return nil
+}
+
+// String is a message of template 4.
+type String struct {
+	TemplateID       uint    `fast:"*"`
+	MandatoryAscii   string  `fast:"1"`
+	OptionalAscii    *string `fast:"2"`
+	MandatoryUnicode string  `fast:"3"`
+	OptionalUnicode  *string `fast:"4"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *String) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *String) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *String) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *String) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *String) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *String) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *String) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *String) Unlock() { m.state.unlock() }
+
+func (m *String) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *String) templateID() uint { return 4 }
+
+func (m *String) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.MandatoryAscii = field.Value.(string)
+	case 2:
+		v := field.Value.(string)
+		m.OptionalAscii = &v
+	case 3:
+		m.MandatoryUnicode = field.Value.(string)
+	case 4:
+		v := field.Value.(string)
+		m.OptionalUnicode = &v
+	}
+}
+
+func (m *String) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.MandatoryAscii
+	case 2:
+		if m.OptionalAscii != nil {
+			field.Value = *m.OptionalAscii
+		}
+	case 3:
+		field.Value = m.MandatoryUnicode
+	case 4:
+		if m.OptionalUnicode != nil {
+			field.Value = *m.OptionalUnicode
+		}
+	}
+}
+
+func (m *String) setLength(field *fast.Field) {
+}
+
+func (m *String) getLength(field *fast.Field) {
+}
+
+func (m *String) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Integer is a message of template 5.
+type Integer struct {
+	TemplateID      uint    `fast:"*"`
+	MandatoryUint32 uint32  `fast:"1"`
+	OptionalUint32  *uint32 `fast:"2"`
+	MandatoryUint64 uint64  `fast:"3"`
+	OptionalUint64  *uint64 `fast:"4"`
+	MandatoryInt32  int32   `fast:"5"`
+	OptionalInt32   *int32  `fast:"6"`
+	MandatoryInt64  int64   `fast:"7"`
+	OptionalInt64   *int64  `fast:"8"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Integer) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Integer) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Integer) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Integer) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Integer) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Integer) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Integer) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Integer) Unlock() { m.state.unlock() }
+
+func (m *Integer) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Integer) templateID() uint { return 5 }
+
+func (m *Integer) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.MandatoryUint32 = field.Value.(uint32)
+	case 2:
+		v := field.Value.(uint32)
+		m.OptionalUint32 = &v
+	case 3:
+		m.MandatoryUint64 = field.Value.(uint64)
+	case 4:
+		v := field.Value.(uint64)
+		m.OptionalUint64 = &v
+	case 5:
+		m.MandatoryInt32 = field.Value.(int32)
+	case 6:
+		v := field.Value.(int32)
+		m.OptionalInt32 = &v
+	case 7:
+		m.MandatoryInt64 = field.Value.(int64)
+	case 8:
+		v := field.Value.(int64)
+		m.OptionalInt64 = &v
+	}
+}
+
+func (m *Integer) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.MandatoryUint32
+	case 2:
+		if m.OptionalUint32 != nil {
+			field.Value = *m.OptionalUint32
+		}
+	case 3:
+		field.Value = m.MandatoryUint64
+	case 4:
+		if m.OptionalUint64 != nil {
+			field.Value = *m.OptionalUint64
+		}
+	case 5:
+		field.Value = m.MandatoryInt32
+	case 6:
+		if m.OptionalInt32 != nil {
+			field.Value = *m.OptionalInt32
+		}
+	case 7:
+		field.Value = m.MandatoryInt64
+	case 8:
+		if m.OptionalInt64 != nil {
+			field.Value = *m.OptionalInt64
+		}
+	}
+}
+
+func (m *Integer) setLength(field *fast.Field) {
+}
+
+func (m *Integer) getLength(field *fast.Field) {
+}
+
+func (m *Integer) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Group is a message of template 6.
+type Group struct {
+	TemplateID uint            `fast:"*"`
+	TestData   uint32          `fast:"1"`
+	OuterGroup GroupOuterGroup `fast:"OuterGroup"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Group) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Group) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Group) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Group) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Group) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Group) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Group) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Group) Unlock() { m.state.unlock() }
+
+func (m *Group) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Group) templateID() uint { return 6 }
+
+func (m *Group) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.TestData = field.Value.(uint32)
+	}
+}
+
+func (m *Group) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.TestData
+	}
+}
+
+func (m *Group) setLength(field *fast.Field) {
+}
+
+func (m *Group) getLength(field *fast.Field) {
+}
+
+func (m *Group) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "OuterGroup":
+		return &m.OuterGroup
+	}
+	return nil
+}
+
+// GroupOuterGroup is a group OuterGroup of Group.
+type GroupOuterGroup struct {
+	OuterTestData uint32                     `fast:"2"`
+	InnerGroup    *GroupOuterGroupInnerGroup `fast:"InnerGroup"`
+}
+
+func (m *GroupOuterGroup) setTemplateID(uint) {}
+
+func (m *GroupOuterGroup) templateID() uint { return 0 }
+
+func (m *GroupOuterGroup) setValue(field *fast.Field) {
+	switch field.ID {
+	case 2:
+		m.OuterTestData = field.Value.(uint32)
+	}
+}
+
+func (m *GroupOuterGroup) getValue(field *fast.Field) {
+	switch field.ID {
+	case 2:
+		field.Value = m.OuterTestData
+	}
+}
+
+func (m *GroupOuterGroup) setLength(field *fast.Field) {
+}
+
+func (m *GroupOuterGroup) getLength(field *fast.Field) {
+}
+
+func (m *GroupOuterGroup) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "InnerGroup":
+		if m.InnerGroup == nil {
+			m.InnerGroup = new(GroupOuterGroupInnerGroup)
+		}
+		return m.InnerGroup
+	}
+	return nil
+}
+
+// GroupOuterGroupInnerGroup is a group InnerGroup of GroupOuterGroup.
+type GroupOuterGroupInnerGroup struct {
+	InnerTestData uint32 `fast:"3"`
+}
+
+func (m *GroupOuterGroupInnerGroup) setTemplateID(uint) {}
+
+func (m *GroupOuterGroupInnerGroup) templateID() uint { return 0 }
+
+func (m *GroupOuterGroupInnerGroup) setValue(field *fast.Field) {
+	switch field.ID {
+	case 3:
+		m.InnerTestData = field.Value.(uint32)
+	}
+}
+
+func (m *GroupOuterGroupInnerGroup) getValue(field *fast.Field) {
+	switch field.ID {
+	case 3:
+		field.Value = m.InnerTestData
+	}
+}
+
+func (m *GroupOuterGroupInnerGroup) setLength(field *fast.Field) {
+}
+
+func (m *GroupOuterGroupInnerGroup) getLength(field *fast.Field) {
+}
+
+func (m *GroupOuterGroupInnerGroup) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Tail is a message of template 7.
+type Tail struct {
+	TemplateID  uint    `fast:"*"`
+	TailAscii   string  `fast:"1"`
+	TailUnicode *string `fast:"2"`
+	TailVector  []byte  `fast:"3"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Tail) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Tail) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Tail) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Tail) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Tail) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Tail) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Tail) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Tail) Unlock() { m.state.unlock() }
+
+func (m *Tail) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Tail) templateID() uint { return 7 }
+
+func (m *Tail) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.TailAscii = field.Value.(string)
+	case 2:
+		v := field.Value.(string)
+		m.TailUnicode = &v
+	case 3:
+		m.TailVector = field.Value.([]byte)
+	}
+}
+
+func (m *Tail) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.TailAscii
+	case 2:
+		if m.TailUnicode != nil {
+			field.Value = *m.TailUnicode
+		}
+	case 3:
+		field.Value = m.TailVector
+	}
+}
+
+func (m *Tail) setLength(field *fast.Field) {
+}
+
+func (m *Tail) getLength(field *fast.Field) {
+}
+
+func (m *Tail) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Delta is a message of template 8.
+type Delta struct {
+	TemplateID   uint    `fast:"*"`
+	DeltaAscii   string  `fast:"1"`
+	DeltaUnicode *string `fast:"2"`
+	DeltaVector  []byte  `fast:"3"`
+	DeltaUint32  uint32  `fast:"4"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Delta) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Delta) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Delta) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Delta) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Delta) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Delta) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Delta) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Delta) Unlock() { m.state.unlock() }
+
+func (m *Delta) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Delta) templateID() uint { return 8 }
+
+func (m *Delta) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.DeltaAscii = field.Value.(string)
+	case 2:
+		v := field.Value.(string)
+		m.DeltaUnicode = &v
+	case 3:
+		m.DeltaVector = field.Value.([]byte)
+	case 4:
+		m.DeltaUint32 = field.Value.(uint32)
+	}
+}
+
+func (m *Delta) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.DeltaAscii
+	case 2:
+		if m.DeltaUnicode != nil {
+			field.Value = *m.DeltaUnicode
+		}
+	case 3:
+		field.Value = m.DeltaVector
+	case 4:
+		field.Value = m.DeltaUint32
+	}
+}
+
+func (m *Delta) setLength(field *fast.Field) {
+}
+
+func (m *Delta) getLength(field *fast.Field) {
+}
+
+func (m *Delta) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Header is a message of template 9.
+type Header struct {
+	TemplateID   uint   `fast:"*"`
+	HeaderSeqNum uint32 `fast:"34"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Header) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Header) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Header) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Header) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Header) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Header) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Header) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Header) Unlock() { m.state.unlock() }
+
+func (m *Header) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Header) templateID() uint { return 9 }
+
+func (m *Header) setValue(field *fast.Field) {
+	switch field.ID {
+	case 34:
+		m.HeaderSeqNum = field.Value.(uint32)
+	}
+}
+
+func (m *Header) getValue(field *fast.Field) {
+	switch field.ID {
+	case 34:
+		field.Value = m.HeaderSeqNum
+	}
+}
+
+func (m *Header) setLength(field *fast.Field) {
+}
+
+func (m *Header) getLength(field *fast.Field) {
+}
+
+func (m *Header) lock(field *fast.Field) segment {
+	return nil
+}
+
+// StaticReference is a message of template 10.
+type StaticReference struct {
+	TemplateID   uint   `fast:"*"`
+	HeaderSeqNum uint32 `fast:"34"`
+	RefData      string `fast:"1"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *StaticReference) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *StaticReference) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *StaticReference) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *StaticReference) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *StaticReference) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *StaticReference) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *StaticReference) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *StaticReference) Unlock() { m.state.unlock() }
+
+func (m *StaticReference) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *StaticReference) templateID() uint { return 10 }
+
+func (m *StaticReference) setValue(field *fast.Field) {
+	switch field.ID {
+	case 34:
+		m.HeaderSeqNum = field.Value.(uint32)
+	case 1:
+		m.RefData = field.Value.(string)
+	}
+}
+
+func (m *StaticReference) getValue(field *fast.Field) {
+	switch field.ID {
+	case 34:
+		field.Value = m.HeaderSeqNum
+	case 1:
+		field.Value = m.RefData
+	}
+}
+
+func (m *StaticReference) setLength(field *fast.Field) {
+}
+
+func (m *StaticReference) getLength(field *fast.Field) {
+}
+
+func (m *StaticReference) lock(field *fast.Field) segment {
+	return nil
+}
+
+// DynamicReference is a message of template 11.
+type DynamicReference struct {
+	TemplateID  uint          `fast:"*"`
+	OuterData   uint32        `fast:"1"`
+	TemplateRef *fast.Message `fast:"templateRef"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *DynamicReference) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *DynamicReference) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *DynamicReference) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *DynamicReference) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *DynamicReference) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *DynamicReference) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *DynamicReference) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *DynamicReference) Unlock() { m.state.unlock() }
+
+func (m *DynamicReference) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *DynamicReference) templateID() uint { return 11 }
+
+func (m *DynamicReference) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.OuterData = field.Value.(uint32)
+	}
+}
+
+func (m *DynamicReference) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.OuterData
+	}
+}
+
+func (m *DynamicReference) setLength(field *fast.Field) {
+}
+
+func (m *DynamicReference) getLength(field *fast.Field) {
+}
+
+func (m *DynamicReference) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "templateRef":
+		if m.TemplateRef == nil {
+			m.TemplateRef = new(fast.Message)
+		}
+		return generic{m.TemplateRef}
+	}
+	return nil
+}
+
+// DictionaryA is a message of template 12.
+type DictionaryA struct {
+	TemplateID uint   `fast:"*"`
+	Shared     uint32 `fast:"1"`
+	Global     uint32 `fast:"2"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *DictionaryA) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *DictionaryA) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *DictionaryA) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *DictionaryA) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *DictionaryA) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *DictionaryA) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *DictionaryA) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *DictionaryA) Unlock() { m.state.unlock() }
+
+func (m *DictionaryA) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *DictionaryA) templateID() uint { return 12 }
+
+func (m *DictionaryA) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.Shared = field.Value.(uint32)
+	case 2:
+		m.Global = field.Value.(uint32)
+	}
+}
+
+func (m *DictionaryA) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.Shared
+	case 2:
+		field.Value = m.Global
+	}
+}
+
+func (m *DictionaryA) setLength(field *fast.Field) {
+}
+
+func (m *DictionaryA) getLength(field *fast.Field) {
+}
+
+func (m *DictionaryA) lock(field *fast.Field) segment {
+	return nil
+}
+
+// DictionaryB is a message of template 13.
+type DictionaryB struct {
+	TemplateID  uint   `fast:"*"`
+	Shared      uint32 `fast:"1"`
+	OtherGlobal uint32 `fast:"2"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *DictionaryB) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *DictionaryB) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *DictionaryB) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *DictionaryB) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *DictionaryB) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *DictionaryB) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *DictionaryB) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *DictionaryB) Unlock() { m.state.unlock() }
+
+func (m *DictionaryB) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *DictionaryB) templateID() uint { return 13 }
+
+func (m *DictionaryB) setValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		m.Shared = field.Value.(uint32)
+	case 2:
+		m.OtherGlobal = field.Value.(uint32)
+	}
+}
+
+func (m *DictionaryB) getValue(field *fast.Field) {
+	switch field.ID {
+	case 1:
+		field.Value = m.Shared
+	case 2:
+		field.Value = m.OtherGlobal
+	}
+}
+
+func (m *DictionaryB) setLength(field *fast.Field) {
+}
+
+func (m *DictionaryB) getLength(field *fast.Field) {
+}
+
+func (m *DictionaryB) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Reset is a message of template 14.
+type Reset struct {
+	TemplateID uint `fast:"*"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Reset) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Reset) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Reset) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Reset) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Reset) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Reset) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Reset) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Reset) Unlock() { m.state.unlock() }
+
+func (m *Reset) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Reset) templateID() uint { return 14 }
+
+func (m *Reset) setValue(field *fast.Field) {
+}
+
+func (m *Reset) getValue(field *fast.Field) {
+}
+
+func (m *Reset) setLength(field *fast.Field) {
+}
+
+func (m *Reset) getLength(field *fast.Field) {
+}
+
+func (m *Reset) lock(field *fast.Field) segment {
+	return nil
+}
+
+// Benchmark is a message of template 2521.
+type Benchmark struct {
+	TemplateID     uint                      `fast:"*"`
+	MessageType    string                    `fast:"35"`
+	ApplVerID      string                    `fast:"1128"`
+	BeginString    string                    `fast:"8"`
+	SenderCompID   string                    `fast:"49"`
+	MsgSeqNum      uint32                    `fast:"34"`
+	SendingTime    uint64                    `fast:"52"`
+	GroupMDEntries []BenchmarkGroupMDEntries `fast:"GroupMDEntries"`
+
+	state lockState `fast:"-"`
+}
+
+func (m *Benchmark) SetTemplateID(tid uint) { m.state.active(m).setTemplateID(tid) }
+
+func (m *Benchmark) GetTemplateID() uint { return m.state.active(m).templateID() }
+
+func (m *Benchmark) SetValue(field *fast.Field) { m.state.active(m).setValue(field) }
+
+func (m *Benchmark) GetValue(field *fast.Field) { m.state.active(m).getValue(field) }
+
+func (m *Benchmark) SetLength(field *fast.Field) { m.state.active(m).setLength(field) }
+
+func (m *Benchmark) GetLength(field *fast.Field) { m.state.active(m).getLength(field) }
+
+func (m *Benchmark) Lock(field *fast.Field) bool { return m.state.lock(m, field) }
+
+func (m *Benchmark) Unlock() { m.state.unlock() }
+
+func (m *Benchmark) setTemplateID(tid uint) { m.TemplateID = tid }
+
+func (m *Benchmark) templateID() uint { return 2521 }
+
+func (m *Benchmark) setValue(field *fast.Field) {
+	switch field.ID {
+	case 35:
+		m.MessageType = field.Value.(string)
+	case 1128:
+		m.ApplVerID = field.Value.(string)
+	case 8:
+		m.BeginString = field.Value.(string)
+	case 49:
+		m.SenderCompID = field.Value.(string)
+	case 34:
+		m.MsgSeqNum = field.Value.(uint32)
+	case 52:
+		m.SendingTime = field.Value.(uint64)
+	}
+}
+
+func (m *Benchmark) getValue(field *fast.Field) {
+	switch field.ID {
+	case 35:
+		field.Value = m.MessageType
+	case 1128:
+		field.Value = m.ApplVerID
+	case 8:
+		field.Value = m.BeginString
+	case 49:
+		field.Value = m.SenderCompID
+	case 34:
+		field.Value = m.MsgSeqNum
+	case 52:
+		field.Value = m.SendingTime
+	}
+}
+
+func (m *Benchmark) setLength(field *fast.Field) {
+	switch field.Name {
+	case "GroupMDEntries":
+		m.GroupMDEntries = make([]BenchmarkGroupMDEntries, field.Value.(int))
+	}
+}
+
+func (m *Benchmark) getLength(field *fast.Field) {
+	switch field.Name {
+	case "GroupMDEntries":
+		field.Value = len(m.GroupMDEntries)
+	}
+}
+
+func (m *Benchmark) lock(field *fast.Field) segment {
+	switch field.Name {
+	case "GroupMDEntries":
+		return &m.GroupMDEntries[field.Value.(int)]
+	}
+	return nil
+}
+
+// BenchmarkGroupMDEntries is an element of sequence GroupMDEntries of Benchmark.
+type BenchmarkGroupMDEntries struct {
+	MDUpdateAction      *uint32       `fast:"279"`
+	MDEntryType         string        `fast:"269"`
+	MDEntryID           *string       `fast:"278"`
+	Symbol              *string       `fast:"55"`
+	RptSeq              *int32        `fast:"83"`
+	MDEntryDate         *uint32       `fast:"272"`
+	MDEntryTime         *uint32       `fast:"273"`
+	OrigTime            *uint32       `fast:"9412"`
+	OrderSide           *string       `fast:"10504"`
+	MDEntryPx           *fast.Decimal `fast:"270"`
+	MDEntrySize         *fast.Decimal `fast:"271"`
+	AccruedInterestAmt  *fast.Decimal `fast:"5384"`
+	TradeValue          *fast.Decimal `fast:"6143"`
+	Yield               *fast.Decimal `fast:"236"`
+	SettlDate           *uint32       `fast:"64"`
+	SettleType          *string       `fast:"5459"`
+	Price               *fast.Decimal `fast:"44"`
+	PriceType           *int32        `fast:"423"`
+	RepoToPx            *fast.Decimal `fast:"5677"`
+	BuyBackPx           *fast.Decimal `fast:"5558"`
+	BuyBackDate         *uint32       `fast:"5559"`
+	TradingSessionID    *string       `fast:"336"`
+	TradingSessionSubID *string       `fast:"625"`
+	RefOrderID          *string       `fast:"1080"`
+}
+
+func (m *BenchmarkGroupMDEntries) setTemplateID(uint) {}
+
+func (m *BenchmarkGroupMDEntries) templateID() uint { return 0 }
+
+func (m *BenchmarkGroupMDEntries) setValue(field *fast.Field) {
+	switch field.ID {
+	case 279:
+		v := field.Value.(uint32)
+		m.MDUpdateAction = &v
+	case 269:
+		m.MDEntryType = field.Value.(string)
+	case 278:
+		v := field.Value.(string)
+		m.MDEntryID = &v
+	case 55:
+		v := field.Value.(string)
+		m.Symbol = &v
+	case 83:
+		v := field.Value.(int32)
+		m.RptSeq = &v
+	case 272:
+		v := field.Value.(uint32)
+		m.MDEntryDate = &v
+	case 273:
+		v := field.Value.(uint32)
+		m.MDEntryTime = &v
+	case 9412:
+		v := field.Value.(uint32)
+		m.OrigTime = &v
+	case 10504:
+		v := field.Value.(string)
+		m.OrderSide = &v
+	case 270:
+		v := field.Value.(fast.Decimal)
+		m.MDEntryPx = &v
+	case 271:
+		v := field.Value.(fast.Decimal)
+		m.MDEntrySize = &v
+	case 5384:
+		v := field.Value.(fast.Decimal)
+		m.AccruedInterestAmt = &v
+	case 6143:
+		v := field.Value.(fast.Decimal)
+		m.TradeValue = &v
+	case 236:
+		v := field.Value.(fast.Decimal)
+		m.Yield = &v
+	case 64:
+		v := field.Value.(uint32)
+		m.SettlDate = &v
+	case 5459:
+		v := field.Value.(string)
+		m.SettleType = &v
+	case 44:
+		v := field.Value.(fast.Decimal)
+		m.Price = &v
+	case 423:
+		v := field.Value.(int32)
+		m.PriceType = &v
+	case 5677:
+		v := field.Value.(fast.Decimal)
+		m.RepoToPx = &v
+	case 5558:
+		v := field.Value.(fast.Decimal)
+		m.BuyBackPx = &v
+	case 5559:
+		v := field.Value.(uint32)
+		m.BuyBackDate = &v
+	case 336:
+		v := field.Value.(string)
+		m.TradingSessionID = &v
+	case 625:
+		v := field.Value.(string)
+		m.TradingSessionSubID = &v
+	case 1080:
+		v := field.Value.(string)
+		m.RefOrderID = &v
+	}
+}
+
+func (m *BenchmarkGroupMDEntries) getValue(field *fast.Field) {
+	switch field.ID {
+	case 279:
+		if m.MDUpdateAction != nil {
+			field.Value = *m.MDUpdateAction
+		}
+	case 269:
+		field.Value = m.MDEntryType
+	case 278:
+		if m.MDEntryID != nil {
+			field.Value = *m.MDEntryID
+		}
+	case 55:
+		if m.Symbol != nil {
+			field.Value = *m.Symbol
+		}
+	case 83:
+		if m.RptSeq != nil {
+			field.Value = *m.RptSeq
+		}
+	case 272:
+		if m.MDEntryDate != nil {
+			field.Value = *m.MDEntryDate
+		}
+	case 273:
+		if m.MDEntryTime != nil {
+			field.Value = *m.MDEntryTime
+		}
+	case 9412:
+		if m.OrigTime != nil {
+			field.Value = *m.OrigTime
+		}
+	case 10504:
+		if m.OrderSide != nil {
+			field.Value = *m.OrderSide
+		}
+	case 270:
+		if m.MDEntryPx != nil {
+			field.Value = *m.MDEntryPx
+		}
+	case 271:
+		if m.MDEntrySize != nil {
+			field.Value = *m.MDEntrySize
+		}
+	case 5384:
+		if m.AccruedInterestAmt != nil {
+			field.Value = *m.AccruedInterestAmt
+		}
+	case 6143:
+		if m.TradeValue != nil {
+			field.Value = *m.TradeValue
+		}
+	case 236:
+		if m.Yield != nil {
+			field.Value = *m.Yield
+		}
+	case 64:
+		if m.SettlDate != nil {
+			field.Value = *m.SettlDate
+		}
+	case 5459:
+		if m.SettleType != nil {
+			field.Value = *m.SettleType
+		}
+	case 44:
+		if m.Price != nil {
+			field.Value = *m.Price
+		}
+	case 423:
+		if m.PriceType != nil {
+			field.Value = *m.PriceType
+		}
+	case 5677:
+		if m.RepoToPx != nil {
+			field.Value = *m.RepoToPx
+		}
+	case 5558:
+		if m.BuyBackPx != nil {
+			field.Value = *m.BuyBackPx
+		}
+	case 5559:
+		if m.BuyBackDate != nil {
+			field.Value = *m.BuyBackDate
+		}
+	case 336:
+		if m.TradingSessionID != nil {
+			field.Value = *m.TradingSessionID
+		}
+	case 625:
+		if m.TradingSessionSubID != nil {
+			field.Value = *m.TradingSessionSubID
+		}
+	case 1080:
+		if m.RefOrderID != nil {
+			field.Value = *m.RefOrderID
+		}
+	}
+}
+
+func (m *BenchmarkGroupMDEntries) setLength(field *fast.Field) {
+}
+
+func (m *BenchmarkGroupMDEntries) getLength(field *fast.Field) {
+}
+
+func (m *BenchmarkGroupMDEntries) lock(field *fast.Field) segment {
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package generated_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+	"github.com/co11ter/goFAST/framing"
+	"github.com/co11ter/goFAST/internal/generated"
+)
+
+// types without methods are decoded and encoded by reflection
+type (
+	reflectedDecimal         generated.Decimal
+	reflectedSequence        generated.Sequence
+	reflectedByteVector      generated.ByteVector
+	reflectedString          generated.String
+	reflectedInteger         generated.Integer
+	reflectedGroup           generated.Group
+	reflectedTail            generated.Tail
+	reflectedDelta           generated.Delta
+	reflectedStaticReference generated.StaticReference
+	reflectedBenchmark       generated.Benchmark
+)
+
+func templates(t testing.TB) []*fast.Template {
+	ftpl, err := os.Open("../../testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftpl.Close()
+
+	tpls, err := fast.ParseXMLTemplate(ftpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpls
+}
+
+func encode(msg interface{}, t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := fast.NewEncoder(&buf, templates(t)...).Encode(msg); err != nil {
+		t.Fatal("can not encode", err)
+	}
+	return buf.Bytes()
+}
+
+func decode(data []byte, msg interface{}, t *testing.T) {
+	if _, err := fast.NewDecoder(nil, templates(t)...).DecodeBytes(data, msg); err != nil {
+		t.Fatal("can not decode", err)
+	}
+}
+
+func ptrUint32(v uint32) *uint32 { return &v }
+func ptrUint64(v uint64) *uint64 { return &v }
+func ptrInt32(v int32) *int32    { return &v }
+func ptrInt64(v int64) *int64    { return &v }
+func ptrString(v string) *string { return &v }
+
+func TestTemplates(t *testing.T) {
+	decimal := &generated.Decimal{
+		TemplateID:        1,
+		CopyDecimal:       &fast.Decimal{Mantissa: 515, Exponent: -2},
+		MandatoryDecimal:  fast.Decimal{Mantissa: 1546, Exponent: -1},
+		IndividualDecimal: fast.Decimal{Mantissa: 64, Exponent: -4},
+	}
+	sequence := &generated.Sequence{
+		TemplateID: 2,
+		TestData:   1,
+		OuterSequence: []generated.SequenceOuterSequence{
+			{OuterTestData: 2, InnerSequence: []generated.SequenceOuterSequenceInnerSequence{{InnerTestData: 3}, {InnerTestData: 4}}},
+			{OuterTestData: 5, InnerSequence: []generated.SequenceOuterSequenceInnerSequence{{InnerTestData: 6}}},
+		},
+		NextOuterSequence: []generated.SequenceNextOuterSequence{{NextOuterTestData: 7}},
+	}
+	byteVector := &generated.ByteVector{
+		TemplateID:      3,
+		MandatoryVector: []byte{0x01, 0x02},
+		OptionalVector:  []byte{0x03},
+	}
+	str := &generated.String{
+		TemplateID:       4,
+		MandatoryAscii:   "abc",
+		OptionalAscii:    ptrString("d"),
+		MandatoryUnicode: "юникод",
+	}
+	integer := &generated.Integer{
+		TemplateID:      5,
+		MandatoryUint32: 1,
+		OptionalUint32:  ptrUint32(2),
+		MandatoryUint64: 3,
+		OptionalUint64:  ptrUint64(4),
+		MandatoryInt32:  -4,
+		OptionalInt32:   ptrInt32(5),
+		MandatoryInt64:  -6,
+		OptionalInt64:   ptrInt64(7),
+	}
+	group := &generated.Group{
+		TemplateID: 6,
+		TestData:   1,
+		OuterGroup: generated.GroupOuterGroup{
+			OuterTestData: 2,
+			InnerGroup:    &generated.GroupOuterGroupInnerGroup{InnerTestData: 3},
+		},
+	}
+	tail := &generated.Tail{TemplateID: 7, TailAscii: "abc", TailUnicode: ptrString("abd"), TailVector: []byte{0x01}}
+	delta := &generated.Delta{TemplateID: 8, DeltaAscii: "abc", DeltaVector: []byte{0x01}, DeltaUint32: 9}
+	reference := &generated.StaticReference{TemplateID: 10, HeaderSeqNum: 5, RefData: "ab"}
+
+	cases := []struct {
+		msg, reflected, decoded, reflectedDecoded interface{}
+	}{
+		{decimal, (*reflectedDecimal)(decimal), new(generated.Decimal), new(reflectedDecimal)},
+		{sequence, (*reflectedSequence)(sequence), new(generated.Sequence), new(reflectedSequence)},
+		{byteVector, (*reflectedByteVector)(byteVector), new(generated.ByteVector), new(reflectedByteVector)},
+		{str, (*reflectedString)(str), new(generated.String), new(reflectedString)},
+		{integer, (*reflectedInteger)(integer), new(generated.Integer), new(reflectedInteger)},
+		{group, (*reflectedGroup)(group), new(generated.Group), new(reflectedGroup)},
+		{tail, (*reflectedTail)(tail), new(generated.Tail), new(reflectedTail)},
+		{delta, (*reflectedDelta)(delta), new(generated.Delta), new(reflectedDelta)},
+		{reference, (*reflectedStaticReference)(reference), new(generated.StaticReference), new(reflectedStaticReference)},
+	}
+	for _, c := range cases {
+		data := encode(c.msg, t)
+		if expect := encode(c.reflected, t); !bytes.Equal(data, expect) {
+			t.Fatalf("data is not equal. current: %x expected: %x", data, expect)
+		}
+
+		decode(data, c.decoded, t)
+		if !reflect.DeepEqual(c.decoded, c.msg) {
+			t.Fatal("messages is not equal, got: ", c.decoded, ", expect: ", c.msg)
+		}
+
+		decode(data, c.reflectedDecoded, t)
+		if !reflect.DeepEqual(c.reflectedDecoded, c.reflected) {
+			t.Fatal("messages is not equal, got: ", c.reflectedDecoded, ", expect: ", c.reflected)
+		}
+	}
+}
+
+func TestDynamicReference(t *testing.T) {
+	data := []byte{0xc0, 0x8b, 0x81, 0xc0, 0x89, 0x87}
+
+	var msg generated.DynamicReference
+	decode(data, &msg, t)
+	if msg.OuterData != 1 || msg.TemplateRef == nil || msg.TemplateRef.TemplateID != 9 {
+		t.Fatal("unexpected message", msg)
+	}
+	if value, _ := msg.TemplateRef.Get("HeaderSeqNum"); value != uint32(7) {
+		t.Fatal("unexpected HeaderSeqNum", value)
+	}
+
+	if current := encode(&msg, t); !bytes.Equal(current, data) {
+		t.Fatalf("data is not equal. current: %x expected: %x", current, data)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	file, err := os.Open("../../testdata/data.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var buf bytes.Buffer
+	reflectedFile, err := os.Open("../../testdata/data.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reflectedFile.Close()
+
+	d := framing.NewDecoder(file, framing.SeqNum, templates(t)...)
+	rd := framing.NewDecoder(reflectedFile, framing.SeqNum, templates(t)...)
+	e := fast.NewEncoder(&buf, templates(t)...)
+	rd2 := fast.NewDecoder(&buf, templates(t)...)
+	for count := 0; ; count++ {
+		var msg generated.Benchmark
+		_, err := d.Decode(&msg)
+		if err == io.EOF {
+			if count == 0 {
+				t.Fatal("there are no messages")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal("can not decode", err)
+		}
+
+		var reflected reflectedBenchmark
+		if _, err = rd.Decode(&reflected); err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if !reflect.DeepEqual(generated.Benchmark(reflected), msg) {
+			t.Fatal("messages is not equal, got: ", msg, ", expect: ", reflected)
+		}
+
+		if err = e.Encode(&msg); err != nil {
+			t.Fatal("can not encode", err)
+		}
+		var decoded generated.Benchmark
+		if err = rd2.Decode(&decoded); err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if !reflect.DeepEqual(decoded, msg) {
+			t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+		}
+	}
+}
+
+func BenchmarkDecoder_DecodeGenerated(b *testing.B) {
+	data, err := ioutil.ReadFile("../../testdata/data.dat")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tpls := templates(b)
+	var msg generated.Benchmark
+	var d *fast.Decoder
+
+	b.ResetTimer()
+	for i, pos := 0, len(data); i < b.N; i++ {
+		if pos == len(data) {
+			pos = 0
+			d = fast.NewDecoder(nil, tpls...)
+		}
+		n, err := d.DecodeBytes(data[pos+4:], &msg) // the first 4 bytes are sequence number
+		if err != nil {
+			b.Fatal(err)
+		}
+		pos += 4 + n
+	}
+	b.ReportAllocs()
+}
@@ -5,29 +5,19 @@
 package fast
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 )
 
+// writerLog logs encoding. Encoded bytes are logged by writer.
 type writerLog struct {
-	*bytes.Buffer
 	log io.Writer
 
 	prefix string // prefix for line
 }
 
 func wrapWriterLog(writer io.Writer) *writerLog {
-	return &writerLog{&bytes.Buffer{}, writer, "\n"}
-}
-
-func (l *writerLog) Write(b []byte) (n int, err error) {
-	_, _ = l.log.Write([]byte(fmt.Sprintf("%x", b)))
-	return 	l.Buffer.Write(b)
-}
-
-func (l *writerLog) WriteTo(w io.Writer) (n int64, err error) {
-	return l.Buffer.WriteTo(w)
+	return &writerLog{writer, "\n"}
 }
 
 func (l *writerLog) Shift() {
@@ -42,24 +32,15 @@ func (l *writerLog) Log(param ...interface{}) {
 	_, _ = l.log.Write(append([]byte(l.prefix), []byte(fmt.Sprint(param...))...))
 }
 
+// readerLog logs decoding. Consumed bytes are logged by reader.
 type readerLog struct {
-	io.Reader
 	log io.Writer
 
 	prefix string // prefix for line
 }
 
-func wrapReaderLog(reader io.Reader, writer io.Writer) *readerLog {
-	return &readerLog{reader,writer, "\n"}
-}
-
-func (l *readerLog) Read(b []byte) (n int, err error) {
-	n, err = l.Reader.Read(b)
-	if err != nil {
-		return
-	}
-	_, err = l.log.Write([]byte(fmt.Sprintf("%x", b)))
-	return n, err
+func wrapReaderLog(writer io.Writer) *readerLog {
+	return &readerLog{writer, "\n"}
 }
 
 func (l *readerLog) Shift() {
@@ -6,7 +6,8 @@ package fast
 
 // Sender is interface for getting data avoid reflection.
 type Sender interface {
-	// GetTemplateID must return template id for message.
+	// GetTemplateID must return template id for message. It's called also after Lock
+	// of dynamic template reference and must return template id of nested message.
 	GetTemplateID() uint
 
 	// GetValue must set actual value to Field.Value for Field.Name or Field.ID.
@@ -15,14 +16,16 @@ type Sender interface {
 	// GetLength must set actual sequence length to Field.Value for Field.Name or Field.ID.
 	GetLength(*Field)
 
-	// Lock indicates a group or sequence. Field.Value will contain index of sequence
+	// Lock indicates a group, bit group, sequence or dynamic template reference. Field.Value will
+	// contain index of sequence. Field.Name is "templateRef" for template reference.
 	Lock(*Field) bool
 	Unlock()
 }
 
 // Receiver is interface for setting data avoid reflection.
 type Receiver interface {
-	// SetTemplateID indicates template id for message.
+	// SetTemplateID indicates template id for message. It's called also after Lock
+	// of dynamic template reference with template id of nested message.
 	SetTemplateID(uint)
 
 	// SetValue indicates actual Field.Value for Field.Name or Field.ID.
@@ -31,7 +34,151 @@ type Receiver interface {
 	// SetLength indicates length of sequence.
 	SetLength(*Field)
 
-	// Lock indicates a group or sequence. Field.Value will contain index of sequence
+	// Lock indicates a group, bit group, sequence or dynamic template reference. Field.Value will
+	// contain index of sequence. Field.Name is "templateRef" for template reference.
 	Lock(*Field) bool
 	Unlock()
 }
+
+// Message is a generic message, which implements Receiver and Sender, so message of
+// any template can be decoded and encoded without definition of Go struct. Value of
+// group, bit group and template reference is *Message, value of sequence is []Message.
+// Decoding of message replaces all fields. No thread safe!
+type Message struct {
+	TemplateID uint
+	Fields     []MessageField // fields in order of decoding
+
+	locked []*Message // locked nested messages, the last one is active
+}
+
+// MessageField is a field of generic message.
+type MessageField struct {
+	ID    uint
+	Name  string
+	Value interface{}
+}
+
+// Get returns value of field by name.
+func (m *Message) Get(name string) (interface{}, bool) {
+	for i := range m.Fields {
+		if m.Fields[i].Name == name {
+			return m.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets value of field by name or appends new field.
+func (m *Message) Set(name string, value interface{}) {
+	m.set(0, name, value)
+}
+
+func (m *Message) SetTemplateID(tid uint) {
+	current := m.current()
+	if current == m {
+		m.Fields = m.Fields[:0] // new message is decoded
+	}
+	current.TemplateID = tid
+}
+
+func (m *Message) SetValue(field *Field) {
+	m.current().set(field.ID, field.Name, field.Value)
+}
+
+func (m *Message) SetLength(field *Field) {
+	m.current().set(field.ID, field.Name, make([]Message, field.Value.(int)))
+}
+
+func (m *Message) GetTemplateID() uint {
+	return m.current().TemplateID
+}
+
+func (m *Message) GetValue(field *Field) {
+	if f := m.current().lookup(field); f != nil {
+		field.Value = f.Value
+	}
+}
+
+func (m *Message) GetLength(field *Field) {
+	field.Value = 0
+	if f := m.current().lookup(field); f != nil {
+		if seq, ok := f.Value.([]Message); ok {
+			field.Value = len(seq)
+		}
+	}
+}
+
+// Lock activates nested message of group, sequence element or template reference.
+// Nested message of group or template reference is created if it does not exist.
+func (m *Message) Lock(field *Field) bool {
+	current := m.current()
+	f := current.lookup(field)
+
+	if index, ok := field.Value.(int); ok && field.Name != tagTemplateRef {
+		if f == nil {
+			return false
+		}
+		seq, ok := f.Value.([]Message)
+		if !ok || index >= len(seq) {
+			return false
+		}
+		m.locked = append(m.locked, &seq[index])
+		return true
+	}
+
+	var nested *Message
+	if f != nil {
+		nested, _ = f.Value.(*Message)
+	}
+	if nested == nil {
+		nested = &Message{}
+		current.set(field.ID, field.Name, nested)
+	}
+	m.locked = append(m.locked, nested)
+	return true
+}
+
+func (m *Message) Unlock() {
+	m.locked = m.locked[:len(m.locked)-1]
+}
+
+// reset deactivates nested messages before new message is decoded or encoded, they
+// stay locked if previous one is failed.
+func (m *Message) reset() {
+	m.locked = m.locked[:0]
+}
+
+// current returns active message.
+func (m *Message) current() *Message {
+	if len(m.locked) == 0 {
+		return m
+	}
+	return m.locked[len(m.locked)-1]
+}
+
+// lookup returns field by id, or by name if id is not defined.
+func (m *Message) lookup(field *Field) *MessageField {
+	for i := range m.Fields {
+		if m.Fields[i].is(field.ID, field.Name) {
+			return &m.Fields[i]
+		}
+	}
+	return nil
+}
+
+func (m *Message) set(id uint, name string, value interface{}) {
+	for i := range m.Fields {
+		if m.Fields[i].is(id, name) {
+			m.Fields[i].Value = value
+			return
+		}
+	}
+	m.Fields = append(m.Fields, MessageField{ID: id, Name: name, Value: value})
+}
+
+func (f *MessageField) is(id uint, name string) bool {
+	if f.ID != 0 && id != 0 {
+		return f.ID == id
+	}
+	return f.Name == name
+}
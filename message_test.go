@@ -0,0 +1,98 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+)
+
+func roundTrip(data [][]byte, t *testing.T) {
+	var buf bytes.Buffer
+	d := newDecoder(nil, t)
+	e := newEncoder(&buf, t)
+	e.SetForceTemplateID(true) // each message of data contains template id
+
+	var msg fast.Message
+	for _, expect := range data {
+		if _, err := d.DecodeBytes(expect, &msg); err != nil {
+			t.Fatal("can not decode", err)
+		}
+		if err := e.Encode(&msg); err != nil {
+			t.Fatal("can not encode", err)
+		}
+		if !bytes.Equal(buf.Bytes(), expect) {
+			t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+		}
+		buf.Reset()
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	cases := [][]byte{
+		decimalData1,
+		sequenceData1,
+		byteVectorData1,
+		stringData1,
+		integerData1,
+		tailData1,
+		deltaData1,
+		staticReferenceData1,
+		dynamicReferenceData1,
+		groupData1,
+	}
+	for _, data := range cases {
+		roundTrip([][]byte{data}, t)
+	}
+
+	roundTrip(benchMessages(t), t)
+}
+
+func TestMessageSequence(t *testing.T) {
+	var msg fast.Message
+	if _, err := newDecoder(nil, t).DecodeBytes(benchMessages(t)[0], &msg); err != nil {
+		t.Fatal("can not decode", err)
+	}
+
+	if msg.TemplateID != 2521 {
+		t.Fatal("expected template 2521, got", msg.TemplateID)
+	}
+	if value, ok := msg.Get("MsgSeqNum"); !ok || value != uint32(0x16602) {
+		t.Fatal("unexpected MsgSeqNum", value)
+	}
+
+	value, _ := msg.Get("GroupMDEntries")
+	entries, ok := value.([]fast.Message)
+	if !ok || len(entries) == 0 {
+		t.Fatal("expected entries, got", value)
+	}
+	if _, ok := entries[0].Get("MDEntryType"); !ok {
+		t.Fatal("entry has no MDEntryType", entries[0])
+	}
+}
+
+func TestMessageDecodeAfterError(t *testing.T) {
+	d := newDecoder(nil, t)
+
+	// decoding fails in nested message of group
+	var msg fast.Message
+	if _, err := d.DecodeBytes(groupData1[:len(groupData1)-1], &msg); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := d.DecodeBytes(integerData1, &msg); err != nil {
+		t.Fatal("can not decode", err)
+	}
+
+	var expect fast.Message
+	if _, err := newDecoder(nil, t).DecodeBytes(integerData1, &expect); err != nil {
+		t.Fatal("can not decode", err)
+	}
+	if msg.TemplateID != expect.TemplateID || !reflect.DeepEqual(msg.Fields, expect.Fields) {
+		t.Fatal("messages is not equal, got: ", msg, ", expect: ", expect)
+	}
+}
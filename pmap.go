@@ -4,8 +4,6 @@
 
 package fast
 
-const defaultMask = 128
-
 type pMap struct {
 	bitmap uint
 	mask uint
@@ -23,6 +21,11 @@ func (p *pMap) SetNextBit(v bool) {
 	}
 }
 
+// hasUnusedBits returns true if any of not visited bits is set.
+func (p *pMap) hasUnusedBits() bool {
+	return (p.bitmap & (p.mask - 1)) != 0
+}
+
 func (p *pMap) String() (res string) {
 	mask := p.mask
 	for mask > 0 {
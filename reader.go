@@ -6,18 +6,31 @@ package fast
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"math"
 )
 
 const (
-	maxLoadBytes = (32 << (^uint(0) >> 63)) * 8 / 7 // max size of 7-th bits data
+	maxLoadBytes = (32 << (^uint(0) >> 63)) / 7 // max count of 7-th bits data bytes in presence map
+
+	readBufferSize = 4096 // size of window filled from io.Reader
+	maxEmptyReads  = 100  // count of empty reads from io.Reader before io.ErrNoProgress
 )
 
-// reader reads type data from io.Reader. No thread safe!
+// reader reads type data from window of bytes. The window is filled from io.Reader
+// when it is consumed. No thread safe!
 type reader struct {
-	reader io.Reader
+	src  io.Reader // source of data, nil if reader decodes window only
+	buf  []byte    // window of data
+	pos  int       // position of next byte in window
+	data []byte    // own buffer filled from src
+
+	strict bool      // check overlong encoding and bounds of integers
+	offset int64     // count of read bytes
+	log    io.Writer // writes consumed bytes in hex, if it is not nil
+
 	strBuf bytes.Buffer
-	bytes  []byte
 
 	tmpErr  error
 	tmpUint uint64
@@ -29,69 +42,195 @@ type reader struct {
 }
 
 func newReader(r io.Reader) *reader {
-	return &reader{reader: r, strBuf: bytes.Buffer{}, bytes: make([]byte, 1)}
+	return &reader{src: r}
 }
 
-func (r *reader) ReadPMap() (m *pMap, err error) {
-	m = new(pMap)
-	m.mask = 1
-	for i := 0; i < maxLoadBytes; i++ {
-		_, err = r.reader.Read(r.bytes)
+// reset sets window of data to read and resets offset.
+func (r *reader) reset(data []byte) {
+	r.buf = data
+	r.pos = 0
+	r.offset = 0
+}
+
+// fill reads next window of data from source.
+func (r *reader) fill() error {
+	if r.src == nil {
+		return io.EOF
+	}
+
+	if r.data == nil {
+		r.data = make([]byte, readBufferSize)
+	}
+
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := r.src.Read(r.data)
+		r.buf = r.data[:n]
+		r.pos = 0
+		if n > 0 {
+			return nil
+		}
 		if err != nil {
-			return
+			return err
 		}
+	}
+	return io.ErrNoProgress
+}
 
-		m.bitmap <<= 7
-		m.bitmap |= uint(r.bytes[0]) & 0x7F
-		m.mask <<= 7
+// readByte returns next byte of data.
+func (r *reader) readByte() (byte, error) {
+	if r.pos == len(r.buf) {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
 
-		if 0x80 == (r.bytes[0] & 0x80) {
-			return
+	b := r.buf[r.pos]
+	r.pos++
+	r.offset++
+
+	if r.log != nil {
+		_, _ = fmt.Fprintf(r.log, "%02x", b)
+	}
+	return b, nil
+}
+
+// readFull reads len(p) bytes of data to p.
+func (r *reader) readFull(p []byte) error {
+	for n := 0; n < len(p); {
+		if r.pos == len(r.buf) {
+			if err := r.fill(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
 		}
+
+		c := copy(p[n:], r.buf[r.pos:])
+		if r.log != nil {
+			_, _ = fmt.Fprintf(r.log, "%x", r.buf[r.pos:r.pos+c])
+		}
+		r.pos += c
+		r.offset += int64(c)
+		n += c
 	}
+	return nil
+}
 
-	for {
-		_, err = r.reader.Read(r.bytes)
+// read reads up to len(p) bytes of data, which is not decoded yet.
+func (r *reader) read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.pos == len(r.buf) {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf[r.pos:])
+	if r.log != nil {
+		_, _ = fmt.Fprintf(r.log, "%x", r.buf[r.pos:r.pos+n])
+	}
+	r.pos += n
+	r.offset += int64(n)
+	return n, nil
+}
+
+// skip discards n bytes of data.
+func (r *reader) skip(n int64) error {
+	for n > 0 {
+		if r.pos == len(r.buf) {
+			if err := r.fill(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+		}
+
+		c := len(r.buf) - r.pos
+		if int64(c) > n {
+			c = int(n)
+		}
+		if r.log != nil {
+			_, _ = fmt.Fprintf(r.log, "%x", r.buf[r.pos:r.pos+c])
+		}
+		r.pos += c
+		r.offset += int64(c)
+		n -= int64(c)
+	}
+	return nil
+}
+
+// ReadPMap reads presence map. Bits over capacity of bitmap are dropped, it is
+// reportable error R8 in strict mode if any of them is set.
+func (r *reader) ReadPMap() (m *pMap, err error) {
+	var b byte
+	m = new(pMap)
+	m.mask = 1
+	for i := 0; ; i++ {
+		b, err = r.readByte()
 		if err != nil {
 			return
 		}
 
-		if (r.bytes[0] & 0x80) == 0 {
+		if i < maxLoadBytes {
+			m.bitmap <<= 7
+			m.bitmap |= uint(b) & 0x7F
+			m.mask <<= 7
+		} else if r.strict && (b&0x7F) != 0 {
+			return nil, ErrR8
+		}
+
+		if 0x80 == (b & 0x80) {
+			if r.strict && i > 0 && b == 0x80 {
+				return nil, ErrR7
+			}
 			return
 		}
 	}
-
-	// TODO what have to do here?
-	return
 }
 
 func (r *reader) ReadInt(nullable bool) (*int64, error) {
-	_, r.tmpErr = r.reader.Read(r.bytes)
-	if r.tmpErr != nil {
-		return nil, r.tmpErr
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
 	}
 
 	r.tmpDcrm = 1
 
-	if (r.bytes[0] & 0x40) > 0 {
-		r.tmpInt = int64((-1 ^ int8(0x7F)) | int8((r.bytes[0] & 0x7F)))
+	if (b & 0x40) > 0 {
+		r.tmpInt = int64((-1 ^ int8(0x7F)) | int8((b & 0x7F)))
 		r.tmpDcrm = 0
 	} else {
-		r.tmpInt = int64(r.bytes[0] & 0x3F)
+		r.tmpInt = int64(b & 0x3F)
 	}
 
-	for (r.bytes[0] & 0x80) == 0 {
+	first := b
+	for i := 0; (b & 0x80) == 0; i++ {
+		if r.strict && r.tmpInt>>56 != 0 && r.tmpInt>>56 != -1 {
+			return nil, ErrD2
+		}
+
 		r.tmpInt <<= 7
-		_, r.tmpErr = r.reader.Read(r.bytes)
-		if r.tmpErr != nil {
-			return nil, r.tmpErr
+		b, err = r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		r.tmpInt |= int64(b & 0x7F)
+
+		// the first byte is redundant if it contains only the sign
+		if r.strict && i == 0 &&
+			((first == 0x00 && (b&0x40) == 0) || (first == 0x7F && (b&0x40) != 0)) {
+			return nil, ErrR6
 		}
-		r.tmpInt |= int64(r.bytes[0] & 0x7F)
 	}
 
 	if nullable {
 		if r.tmpInt == 0 {
-			return nil, r.tmpErr
+			return nil, nil
 		}
 		r.tmpInt -= r.tmpDcrm
 	}
@@ -100,25 +239,33 @@ func (r *reader) ReadInt(nullable bool) (*int64, error) {
 }
 
 func (r *reader) ReadUint(nullable bool) (*uint64, error) {
-	_, r.tmpErr = r.reader.Read(r.bytes)
-	if r.tmpErr != nil {
-		return nil, r.tmpErr
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
 	}
 
-	r.tmpUint = uint64(r.bytes[0] & 0x7F)
+	r.tmpUint = uint64(b & 0x7F)
+
+	if r.strict && b == 0x00 {
+		return nil, ErrR6
+	}
+
+	for (b & 0x80) == 0 {
+		if r.strict && r.tmpUint>>57 != 0 {
+			return nil, ErrD2
+		}
 
-	for (r.bytes[0] & 0x80) == 0 {
 		r.tmpUint <<= 7
-		_, r.tmpErr = r.reader.Read(r.bytes)
-		if r.tmpErr != nil {
-			return nil, r.tmpErr
+		b, err = r.readByte()
+		if err != nil {
+			return nil, err
 		}
-		r.tmpUint |= uint64(r.bytes[0] & 0x7F)
+		r.tmpUint |= uint64(b & 0x7F)
 	}
 
 	if nullable {
 		if r.tmpUint == 0 {
-			return nil, r.tmpErr
+			return nil, nil
 		}
 		r.tmpUint--
 	}
@@ -126,71 +273,122 @@ func (r *reader) ReadUint(nullable bool) (*uint64, error) {
 	return &r.tmpUint, nil
 }
 
+// ReadByteVector returns new slice, so the value can be saved in dictionary.
 func (r *reader) ReadByteVector(nullable bool) (*[]byte, error) {
 	r.tmpLen, r.tmpErr = r.ReadUint(nullable)
 	if r.tmpErr != nil || r.tmpLen == nil {
 		return nil, r.tmpErr
 	}
 
-	if uint32(*r.tmpLen) > uint32(len(r.tmpByte)) {
-		r.tmpByte = make([]byte, uint32(*r.tmpLen))
-	} else {
-		r.tmpByte = r.tmpByte[:uint32(*r.tmpLen)]
+	// length of byte vector is uInt32
+	if *r.tmpLen > math.MaxUint32 {
+		return nil, ErrD2
+	}
+
+	if *r.tmpLen > uint64(len(r.buf)-r.pos) && r.src == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	// length from io.Reader is not trusted, so the slice grows with read data
+	size := *r.tmpLen
+	if size > readBufferSize && r.src != nil {
+		size = readBufferSize
 	}
 
-	_, r.tmpErr = io.ReadFull(r.reader, r.tmpByte)
-	return &r.tmpByte, nil
+	r.tmpByte = make([]byte, size)
+	for n := 0; ; n = len(r.tmpByte) {
+		r.tmpErr = r.readFull(r.tmpByte[n:])
+		if r.tmpErr != nil {
+			return nil, r.tmpErr
+		}
+
+		rest := *r.tmpLen - uint64(len(r.tmpByte))
+		if rest == 0 {
+			return &r.tmpByte, nil
+		}
+		if size = uint64(len(r.tmpByte)); size > rest {
+			size = rest
+		}
+		r.tmpByte = append(r.tmpByte, make([]byte, size)...)
+	}
 }
 
 // read ascii string
 func (r *reader) ReadString(nullable bool) (*string, error) {
-	_, r.tmpErr = r.reader.Read(r.bytes)
-	if r.tmpErr != nil {
-		return nil, r.tmpErr
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
 	}
 
-	if (r.bytes[0] & 0x7F) == 0 {
-		if r.bytes[0] == 0x80 {
+	if (b & 0x7F) == 0 {
+		r.tmpStr = ""
+		if b == 0x80 {
 			if nullable {
 				return nil, nil
 			}
 			return &r.tmpStr, nil
 		}
 
-		_, r.tmpErr = r.reader.Read(r.bytes)
-		if r.tmpErr != nil {
-			return nil, r.tmpErr
+		b, err = r.readByte()
+		if err != nil {
+			return nil, err
 		}
 
-		if r.bytes[0] == 0x80 {
+		if b == 0x80 {
 			return &r.tmpStr, nil
-		} else if nullable && r.bytes[0] == 0x00 {
-			_, r.tmpErr = r.reader.Read(r.bytes)
-			if r.tmpErr != nil {
-				return nil, r.tmpErr
+		} else if nullable && b == 0x00 {
+			b, err = r.readByte()
+			if err != nil {
+				return nil, err
 			}
 
-			if r.bytes[0] == 0x80 {
+			if b == 0x80 {
 				return &r.tmpStr, nil
 			}
 		}
-		r.tmpErr = ErrR9
-		return nil, r.tmpErr
+		return nil, ErrR9
 	}
 
+	r.strBuf.Reset()
 	for {
-		if (r.bytes[0] & 0x80) > 0 {
-			r.strBuf.WriteByte(r.bytes[0] & 0x7F)
+		if (b & 0x80) > 0 {
+			r.strBuf.WriteByte(b & 0x7F)
 			break
 		}
-		r.strBuf.WriteByte(r.bytes[0])
-		_, r.tmpErr = r.reader.Read(r.bytes)
-		if r.tmpErr != nil {
-			return nil, r.tmpErr
+
+		// copy the rest of string from window at once
+		start := r.pos - 1
+		end := stopIndex(r.buf[r.pos:])
+		if end < 0 {
+			r.strBuf.WriteByte(b)
+			b, err = r.readByte()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		end += r.pos
+		r.strBuf.Write(r.buf[start:end])
+		r.strBuf.WriteByte(r.buf[end] & 0x7F)
+		if r.log != nil {
+			_, _ = fmt.Fprintf(r.log, "%x", r.buf[r.pos:end+1])
 		}
+		r.offset += int64(end + 1 - r.pos)
+		r.pos = end + 1
+		break
 	}
 
 	r.tmpStr = r.strBuf.String()
-	r.strBuf.Reset()
 	return &r.tmpStr, nil
 }
+
+// stopIndex returns index of the first byte with stop bit, or -1 if there is no one.
+func stopIndex(data []byte) int {
+	for i, b := range data {
+		if (b & 0x80) != 0 {
+			return i
+		}
+	}
+	return -1
+}
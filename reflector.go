@@ -5,69 +5,172 @@
 package fast
 
 import (
-	"errors"
+	"math/big"
 	"reflect"
 	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 const structTag = "fast"
 
-var regCache = make(map[string]*register)
+// regCache is a cache of registers by struct type, it is shared by all encoders
+// and decoders.
+var regCache sync.Map
+
+var (
+	decimalType    = reflect.TypeOf(Decimal{})
+	stringType     = reflect.TypeOf("")
+	shopspringType = reflect.TypeOf(decimal.Decimal{})
+	ratType        = reflect.TypeOf(big.Rat{})
+	timeType       = reflect.TypeOf(time.Time{})
+	bytesType      = reflect.TypeOf([]byte(nil))
+)
 
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  stringType,
+}
+
+const (
+	templateIDTag = "*"
+)
+
+// register maps ids and names of instructions to fields of struct type. Fields of
+// embedded structs are promoted, so index of field is a path of indexes.
 type register struct {
 	prefer bool // true for map by id
 	byName map[string]int
 	byID   map[int]int
+	fields [][]int // index paths of struct fields
+	tid    int     // position of template id field in fields, -1 if it is not defined
+}
+
+// unknownIndex is an index of plan, which is not looked up yet.
+const unknownIndex = -2
+
+// plan maps slots of instructions to indexes of struct fields, it is -1 if field
+// is not found.
+type plan []int
+
+// planCache is a cache of plans of struct types for instructions of encoder or
+// decoder. It is not thread safe and is protected by mutex of owner.
+type planCache struct {
+	byType map[reflect.Type]plan
+	slots  int // count of numbered instructions
+}
+
+func newPlanCache() planCache {
+	return planCache{byType: make(map[reflect.Type]plan)}
+}
+
+// number assigns slots of plans to instructions.
+func (c *planCache) number(instructions []*Instruction) {
+	for _, item := range instructions {
+		item.slot = c.slots
+		c.slots++
+		c.number(item.Instructions)
+	}
+}
+
+// get returns plan of struct type, the plan is filled during reflection.
+func (c *planCache) get(rt reflect.Type) plan {
+	p, ok := c.byType[rt]
+	if !ok {
+		p = make(plan, c.slots)
+		for i := range p {
+			p[i] = unknownIndex
+		}
+		c.byType[rt] = p
+	}
+	return p
+}
+
+// scope is a struct of message, group, sequence element or template reference.
+type scope struct {
+	value    reflect.Value // addressable struct
+	register *register
+	plan     plan
 }
 
 type reflector struct {
-	current *register
-	values []reflect.Value
-	index int
+	scopes []scope // locked structs, the last one is active
+	plans  *planCache
+	err    error // the first error of reflection, e.g. conversion to struct field type
 }
 
-func makeMsg(msg interface{}) (m *reflector) {
+func makeMsg(msg interface{}, plans *planCache) (*reflector, error) {
 	rv := reflect.ValueOf(msg)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		panic(errors.New("message is not pointer or nil"))
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, messageTypeError("%T is not a non-nil pointer to struct", msg)
 	}
 
-	m = &reflector{values: []reflect.Value{rv}}
-	rt := reflect.TypeOf(msg).Elem()
+	m := &reflector{plans: plans}
+	if err := m.push(rv.Elem()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
 
-	var ok bool
-	name := rt.PkgPath() + "." + rt.Name()
-	if m.current, ok = regCache[name]; !ok {
-		m.current = &register{byName: make(map[string]int), byID: make(map[int]int)}
-		countID, countName := parseType(rt, m.current)
-		if countID >= countName {
-			m.current.prefer = true
-		}
-		regCache[name] = m.current
+// push makes struct v active.
+func (m *reflector) push(v reflect.Value) error {
+	current, err := getRegister(v.Type())
+	if err != nil {
+		return err
 	}
-	return
+	m.scopes = append(m.scopes, scope{value: v, register: current, plan: m.plans.get(v.Type())})
+	return nil
 }
 
+// current returns active struct.
+func (m *reflector) current() *scope {
+	return &m.scopes[len(m.scopes)-1]
+}
+
+// Lock makes struct of group, sequence element or template reference active. Fields
+// of group, which is not defined in struct, are mapped to fields of the parent struct.
 func (m *reflector) Lock(field *Field) bool {
 	v, ok := m.lookUpRField(field)
 	if !ok {
 		return false
 	}
+	v = extractValue(v)
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		index, ok := field.Value.(int)
+		if !ok || index >= v.Len() {
+			m.fail(messageTypeError("element %v of %s is out of range", field.Value, field.Name))
+			return false
+		}
+		v = extractValue(v.Index(index))
+	}
+	if v.Kind() != reflect.Struct {
+		m.fail(messageTypeError("field %s of type %s is not struct", field.Name, v.Type()))
+		return false
+	}
 
-	if v.Kind() == reflect.Slice {
-		v = extractValue(v.Index(field.Value.(int)))
-		m.values = append(m.values, v.Addr())
-	} else {
-		v = extractValue(v)
-		m.values = append(m.values, v.Addr())
+	if err := m.push(v); err != nil {
+		m.fail(err)
+		return false
 	}
-	m.index++
 	return true
 }
 
 func (m *reflector) Unlock() {
-	m.values = m.values[:m.index]
-	m.index--
+	m.scopes = m.scopes[:len(m.scopes)-1]
 }
 
 // find value in message and assign to field
@@ -75,10 +178,10 @@ func (m *reflector) GetValue(field *Field) {
 	if rField, ok := m.lookUpRField(field); ok {
 		if rField.Kind() == reflect.Ptr {
 			if !rField.IsNil() {
-				field.Value = rField.Elem().Interface()
+				field.Value = valueOf(field.instruction, rField.Elem())
 			}
 		} else {
-			field.Value = rField.Interface()
+			field.Value = valueOf(field.instruction, rField)
 		}
 	}
 }
@@ -86,149 +189,432 @@ func (m *reflector) GetValue(field *Field) {
 // find slice len in message and assign to field
 func (m *reflector) GetLength(field *Field) {
 	if rField, ok := m.lookUpRField(field); ok {
+		if rField.Kind() == reflect.Ptr {
+			if rField.IsNil() {
+				field.Value = 0
+				return
+			}
+			rField = rField.Elem()
+		}
+		if rField.Kind() != reflect.Slice && rField.Kind() != reflect.Array {
+			m.fail(messageTypeError("field %s of type %s is not slice", field.Name, rField.Type()))
+			return
+		}
 		field.Value = rField.Len()
 	}
 }
 
 func (m *reflector) SetLength(field *Field) {
 	if rField, ok := m.lookUpRField(field); ok {
+		rField = extractValue(rField)
 		length := field.Value.(int)
-		if length > rField.Cap() {
-			newValue := reflect.MakeSlice(rField.Type(), length, length)
-			reflect.Copy(newValue, rField)
-			rField.Set(newValue)
-		}
 
-		if length > rField.Len() {
+		switch rField.Kind() {
+		case reflect.Slice:
+			if length > rField.Cap() {
+				newValue := reflect.MakeSlice(rField.Type(), length, length)
+				reflect.Copy(newValue, rField)
+				rField.Set(newValue)
+			}
 			rField.SetLen(length)
+		case reflect.Array:
+			// array is a sequence of fixed length, the rest of elements is reset
+			if length > rField.Len() {
+				m.fail(messageTypeError("length %d of %s exceeds array %s", length, field.Name, rField.Type()))
+				return
+			}
+			zero := reflect.Zero(rField.Type().Elem())
+			for i := length; i < rField.Len(); i++ {
+				rField.Index(i).Set(zero)
+			}
+		default:
+			m.fail(messageTypeError("field %s of type %s is not slice", field.Name, rField.Type()))
 		}
 	}
 }
 
 // find template id in message and return
 func (m *reflector) GetTemplateID() uint {
-	index, ok := m.current.byName["*"]
-	if !ok {
+	s := m.current()
+	if s.register.tid < 0 {
 		return 0
 	}
-	return uint(m.values[m.index].Elem().Field(index).Uint())
+	rField := fieldByIndex(s.value, s.register.fields[s.register.tid])
+	tid, ok := uintOf(rField)
+	if !ok {
+		m.fail(messageTypeError("template id field of type %s is not integer", rField.Type()))
+	}
+	return uint(tid)
 }
 
 // set template id to message
 func (m *reflector) SetTemplateID(tid uint) {
-	index, ok := m.current.byName["*"]
-	if !ok {
+	s := m.current()
+	if s.register.tid < 0 {
 		return
 	}
 
-	rField := m.values[m.index].Elem().Field(index)
-	m.set(rField, reflect.ValueOf(tid))
+	rField := fieldByIndex(s.value, s.register.fields[s.register.tid])
+	m.fail(m.set(rField, reflect.ValueOf(tid)))
 }
 
 // set field value to message
 func (m *reflector) SetValue(field *Field) {
 	if rField, ok := m.lookUpRField(field); ok {
-		m.set(rField, reflect.ValueOf(field.Value))
+		value := reflect.ValueOf(field.Value)
+		m.fail(m.set(rField, convertValue(field.instruction, value, extractType(rField.Type()))))
 	}
 }
 
-func (m *reflector) set(field reflect.Value, value reflect.Value) {
+// fail keeps the first error of reflection until it is flushed.
+func (m *reflector) fail(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// flushErr returns and clears error of reflection.
+func (m *reflector) flushErr() error {
+	err := m.err
+	m.err = nil
+	return err
+}
+
+// set assigns value to field. The value is converted if its type is not assignable
+// to type of field, it returns ErrD1 if the types are not compatible.
+func (m *reflector) set(field reflect.Value, value reflect.Value) error {
 	if field.Kind() == reflect.Ptr {
 		field.Set(reflect.New(field.Type().Elem()))
 		field = field.Elem()
 	}
-	if field.Kind() == reflect.Slice {
+	if value.Type() == decimalType {
+		value = decimalValue(value.Interface().(Decimal), field.Type())
+	}
+
+	switch {
+	case field.Kind() == reflect.Slice && value.Kind() == reflect.Slice &&
+		field.Type().Elem() == value.Type().Elem():
 		newValue := reflect.MakeSlice(field.Type(), value.Len(), value.Len())
 		reflect.Copy(newValue, value)
 		field.Set(newValue)
-	} else {
+	case value.Type().AssignableTo(field.Type()):
 		field.Set(value)
+	default:
+		tmp := reflect.New(castType(field.Type()))
+		if err := castTo(value.Interface(), tmp.Interface()); err != nil {
+			return err
+		}
+		field.Set(tmp.Elem().Convert(field.Type()))
 	}
+	return nil
 }
 
 func (m *reflector) lookUpRField(field *Field) (v reflect.Value, ok bool) {
-	if field.index == nil {
-		m.lookUpIndex(field)
-	}
-	if field.index == nil {
+	s := m.current()
+	pos := s.fieldIndex(field)
+	if pos < 0 {
 		return
 	}
 
-	v = extractValue(m.values[m.index])
-	v = extractValue(v.Field(*field.index))
+	v = fieldByIndex(s.value, s.register.fields[pos])
 	ok = true
 	return
 }
 
-func (m *reflector) lookUpIndex(field *Field) {
-	var v int
-	var ok bool
-	if m.current.prefer {
-		if v, ok = m.current.byID[int(field.ID)]; ok {
-			field.index = &v
-			return
-		}
-		if v, ok = m.current.byName[field.Name]; ok {
-			field.index = &v
-			return
+// fieldIndex returns position of struct field from plan. Fields without instruction,
+// e.g. template reference, are looked up in register.
+func (s *scope) fieldIndex(field *Field) int {
+	if field.instruction == nil {
+		return s.register.lookUp(field)
+	}
+
+	pos := s.plan[field.instruction.slot]
+	if pos == unknownIndex {
+		pos = s.register.lookUp(field)
+		s.plan[field.instruction.slot] = pos
+	}
+	return pos
+}
+
+// lookUp returns position of struct field by id or name, it is -1 if field is not found.
+func (r *register) lookUp(field *Field) int {
+	if r.prefer {
+		if v, ok := r.byID[int(field.ID)]; ok {
+			return v
 		}
 	}
-	if v, ok = m.current.byName[field.Name]; ok {
-		field.index = &v
-		return
+	if v, ok := r.byName[field.Name]; ok {
+		return v
+	}
+	if v, ok := r.byID[int(field.ID)]; ok {
+		return v
+	}
+	return -1
+}
+
+// add adds index path of field and returns its position.
+func (r *register) add(path []int) int {
+	r.fields = append(r.fields, path)
+	return len(r.fields) - 1
+}
+
+// getRegister returns cached register of struct type.
+func getRegister(rt reflect.Type) (*register, error) {
+	if cached, ok := regCache.Load(rt); ok {
+		return cached.(*register), nil
 	}
-	if v, ok = m.current.byID[int(field.ID)]; ok {
-		field.index = &v
+
+	current, err := parseType(rt)
+	if err != nil {
+		return nil, err
 	}
+	cached, _ := regCache.LoadOrStore(rt, current)
+	return cached.(*register), nil
 }
 
-func parseType(rt reflect.Type, current *register) (countID, countName int) {
+// parseType returns register of fields of struct type. Nested structs of groups and
+// sequences have own registers. Fields of embedded structs are promoted, unless
+// the struct has a field with the same tag.
+func parseType(rt reflect.Type) (*register, error) {
+	current := &register{
+		byName: make(map[string]int),
+		byID:   make(map[int]int),
+		tid:    -1,
+	}
+
 	var (
-		field reflect.StructField
-		tmp reflect.Type
-		name string
-		id int
-		err error
-		ok bool
+		countID, countName int
+		embedded []int
 	)
 	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
 
-		field = rt.Field(i)
-
-		name = lookUpTag(field)
+		name := lookUpTag(field)
 		if name == "" {
 			continue
 		}
+		if isEmbedded(field) {
+			embedded = append(embedded, i)
+			continue
+		}
+		// unexported fields can not be set or read
+		if field.PkgPath != "" {
+			continue
+		}
 
-		id, err = strconv.Atoi(name)
-		if err == nil {
+		if name == templateIDTag {
+			current.tid = current.add([]int{i})
+			continue
+		}
+
+		if id, err := strconv.Atoi(name); err == nil {
 			countID++
-			if _, ok = current.byID[id]; ok {
-				panic(errors.New("found duplicate struct field"))
+			if _, ok := current.byID[id]; ok {
+				return nil, messageTypeError("duplicate field %s of %s", name, rt)
 			}
-			current.byID[id] = i
+			current.byID[id] = current.add([]int{i})
 		} else {
 			countName++
-			if _, ok = current.byName[name]; ok {
-				panic(errors.New("found duplicate struct field"))
+			if _, ok := current.byName[name]; ok {
+				return nil, messageTypeError("duplicate field %s of %s", name, rt)
 			}
-			current.byName[name] = i
+			current.byName[name] = current.add([]int{i})
 		}
+	}
 
-		tmp = extractType(field.Type)
+	// fields promoted by several embedded structs are ambiguous
+	promotedNames := make(map[string]bool)
+	promotedIDs := make(map[int]bool)
+	for _, i := range embedded {
+		inner, err := getRegister(extractType(rt.Field(i).Type))
+		if err != nil {
+			return nil, err
+		}
 
-		// extract first element of slice
-		if tmp.Kind() == reflect.Slice {
-			tmp = extractType(tmp.Elem())
+		for name, pos := range inner.byName {
+			if _, ok := current.byName[name]; ok {
+				if promotedNames[name] {
+					return nil, messageTypeError("ambiguous field %s of %s", name, rt)
+				}
+				continue
+			}
+			countName++
+			promotedNames[name] = true
+			current.byName[name] = current.add(append([]int{i}, inner.fields[pos]...))
 		}
+		for id, pos := range inner.byID {
+			if _, ok := current.byID[id]; ok {
+				if promotedIDs[id] {
+					return nil, messageTypeError("ambiguous field %d of %s", id, rt)
+				}
+				continue
+			}
+			countID++
+			promotedIDs[id] = true
+			current.byID[id] = current.add(append([]int{i}, inner.fields[pos]...))
+		}
+		if inner.tid >= 0 && current.tid < 0 {
+			current.tid = current.add(append([]int{i}, inner.fields[inner.tid]...))
+		}
+	}
+
+	current.prefer = countID >= countName
+	return current, nil
+}
 
-		if tmp.Kind() == reflect.Struct {
-			d, n := parseType(tmp, current)
-			countID += d
-			countID += n
+// isEmbedded returns true for embedded struct without tag, its fields are promoted.
+func isEmbedded(field reflect.StructField) bool {
+	if !field.Anonymous {
+		return false
+	}
+	if _, ok := field.Tag.Lookup(structTag); ok {
+		return false
+	}
+
+	rt := extractType(field.Type)
+	if rt.Kind() != reflect.Struct || isValueType(rt) {
+		return false
+	}
+	// pointer to unexported struct can not be allocated
+	return field.Type.Kind() != reflect.Ptr || field.PkgPath == ""
+}
+
+// fieldByIndex returns nested field by index path, nil pointers of embedded
+// structs are allocated.
+func fieldByIndex(v reflect.Value, path []int) reflect.Value {
+	for i, index := range path {
+		if i > 0 {
+			v = extractValue(v)
 		}
+		v = v.Field(index)
 	}
-	return
+	return v
+}
+
+// decimalValue converts decimal to value of type rt if it is float, string,
+// shopspring or rational type. Named types are matched by their underlying type.
+func decimalValue(d Decimal, rt reflect.Type) reflect.Value {
+	var value reflect.Value
+	switch rt.Kind() {
+	case reflect.Float64:
+		value = reflect.ValueOf(d.Float64())
+	case reflect.Float32:
+		value = reflect.ValueOf(float32(d.Float64()))
+	case reflect.String:
+		value = reflect.ValueOf(d.String())
+	case reflect.Struct:
+		switch {
+		case rt.ConvertibleTo(decimalType):
+			value = reflect.ValueOf(d)
+		case rt.ConvertibleTo(shopspringType):
+			value = reflect.ValueOf(d.ToDecimal())
+		case rt.ConvertibleTo(ratType):
+			value = reflect.ValueOf(d.Rat()).Elem()
+		default:
+			return reflect.ValueOf(d)
+		}
+	default:
+		return reflect.ValueOf(d)
+	}
+	return value.Convert(rt)
+}
+
+// valueOf returns value of message field. Values of FAST 1.2 types are converted
+// from named types, integer index of enum and integer mask of set.
+func valueOf(instruction *Instruction, rv reflect.Value) interface{} {
+	if instruction == nil {
+		return rv.Interface()
+	}
+
+	switch instruction.Type {
+	case TypeBoolean:
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool()
+		}
+	case TypeEnum:
+		if rv.Kind() == reflect.String {
+			return rv.String()
+		}
+		if index, ok := uintOf(rv); ok && index < uint64(len(instruction.Elements)) {
+			return instruction.Elements[index]
+		}
+	case TypeSet:
+		if bits, ok := uintOf(rv); ok {
+			names := make([]string, 0, len(instruction.Elements))
+			for index, name := range instruction.Elements {
+				if bits&(1<<uint(index)) != 0 {
+					names = append(names, name)
+				}
+			}
+			return names
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.String {
+			if rv.IsNil() {
+				return nil
+			}
+			names := make([]string, rv.Len())
+			for i := range names {
+				names[i] = rv.Index(i).String()
+			}
+			return names
+		}
+	}
+	return rv.Interface()
+}
+
+// convertValue converts value of FAST 1.2 type to message field type rt: enum
+// to integer index, set to integer mask and values to named types.
+func convertValue(instruction *Instruction, value reflect.Value, rt reflect.Type) reflect.Value {
+	if instruction == nil || !value.IsValid() || value.Type() == rt {
+		return value
+	}
+
+	switch instruction.Type {
+	case TypeEnum:
+		if _, ok := uintOf(reflect.Zero(rt)); ok {
+			return reflect.ValueOf(indexOf(instruction.Elements, value.String())).Convert(rt)
+		}
+	case TypeSet:
+		if _, ok := uintOf(reflect.Zero(rt)); ok {
+			bits, _ := instruction.setBits(value.Interface().([]string))
+			return reflect.ValueOf(bits).Convert(rt)
+		}
+	}
+
+	if value.Kind() == rt.Kind() && value.Kind() != reflect.Slice && value.Type().ConvertibleTo(rt) {
+		return value.Convert(rt)
+	}
+	return value
+}
+
+// uintOf returns value of integer kind, ok is false for other kinds and negative values.
+func uintOf(rv reflect.Value) (uint64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, false
+		}
+		return uint64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), true
+	}
+	return 0, false
+}
+
+// castType returns basic type of kind of rt, castTo converts values to basic types only.
+func castType(rt reflect.Type) reflect.Type {
+	if rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8 {
+		return bytesType
+	}
+	if basic, ok := basicTypes[rt.Kind()]; ok {
+		return basic
+	}
+	return rt
+}
+
+// isValueType returns true for structs which are mapped to a single field.
+func isValueType(rt reflect.Type) bool {
+	return rt == decimalType || rt == shopspringType || rt == ratType || rt == timeType
 }
 
 func extractValue(rv reflect.Value) reflect.Value {
@@ -0,0 +1,110 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/co11ter/goFAST"
+)
+
+// scopedTemplate has field Price in template, group and sequence.
+func scopedTemplate(t *testing.T) *fast.Template {
+	tpl := fast.NewTemplate(1, "Scoped").
+		Uint32("Price", 1).
+		Group("Bid", 2, fast.NewInstructions().
+			Uint32("Price", 1),
+		).
+		Sequence("Levels", 3, fast.NewInstructions().
+			Length("NoLevels", 4).
+			Uint32("Price", 1),
+		).
+		Template()
+	if err := fast.Compile(tpl); err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
+
+type scopedHeader struct {
+	TemplateID uint `fast:"*"`
+	Price      uint32
+}
+
+type scopedLevel struct {
+	Value uint32 `fast:"Price"`
+}
+
+type scopedMessage struct {
+	scopedHeader
+	Bid struct {
+		Price uint32 `fast:"1"`
+	}
+	Levels [2]scopedLevel
+}
+
+func TestReflectionScope(t *testing.T) {
+	tpl := scopedTemplate(t)
+
+	msg := scopedMessage{scopedHeader: scopedHeader{TemplateID: 1, Price: 1}}
+	msg.Bid.Price = 2
+	msg.Levels = [2]scopedLevel{{Value: 3}, {Value: 4}}
+
+	var buf bytes.Buffer
+	if err := fast.NewEncoder(&buf, tpl).Encode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	// pmap, tid, price, bid price, length, level prices
+	expect := []byte{0xc0, 0x81, 0x81, 0x82, 0x82, 0x83, 0x84}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+
+	var decoded scopedMessage
+	if err := fast.NewDecoder(bytes.NewReader(expect), tpl).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+	}
+
+	// the field of struct shadows the field of embedded struct
+	var shadowed struct {
+		scopedHeader
+		Price  uint64
+		Bid    scopedLevel
+		Levels []scopedLevel
+	}
+	if err := fast.NewDecoder(bytes.NewReader(expect), tpl).Decode(&shadowed); err != nil {
+		t.Fatal(err)
+	}
+	if shadowed.Price != 1 || shadowed.scopedHeader.Price != 0 || shadowed.Bid.Value != 2 || len(shadowed.Levels) != 2 {
+		t.Fatal("unexpected message", shadowed)
+	}
+}
+
+func TestReflectionScopeErrors(t *testing.T) {
+	tpl := scopedTemplate(t)
+
+	// three levels do not fit the array
+	data := []byte{0xc0, 0x81, 0x81, 0x82, 0x83, 0x83, 0x84, 0x85}
+	var short scopedMessage
+	err := fast.NewDecoder(bytes.NewReader(data), tpl).Decode(&short)
+	if !errors.Is(err, fast.ErrMessageType) {
+		t.Fatal("expected", fast.ErrMessageType, "got", err)
+	}
+
+	var ambiguous struct {
+		scopedHeader
+		scopedLevel
+	}
+	err = fast.NewDecoder(bytes.NewReader(data), tpl).Decode(&ambiguous)
+	if !errors.Is(err, fast.ErrMessageType) {
+		t.Fatal("expected", fast.ErrMessageType, "got", err)
+	}
+}
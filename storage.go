@@ -4,20 +4,170 @@
 
 package fast
 
-type storage map[string]interface{}
+import "strconv"
+
+const (
+	dictionaryGlobal   = "global"
+	dictionaryTemplate = "template"
+	dictionaryType     = "type"
+)
+
+// storage contains dictionaries by name.
+type storage struct {
+	dictionaries map[string]dictionary
+
+	// template identifier belongs to global dictionary, but it is kept apart
+	// from the keys of fields, so it can not be shared with any of them.
+	templateID        uint
+	templateIDDefined bool
+
+	journal *journal // changes of current message, nil if they are not recorded
+}
+
+// journal records previous values of entries changed by a message, so the changes
+// can be undone if the message fails.
+type journal struct {
+	changes []change
+
+	templateID        uint
+	templateIDDefined bool
+}
+
+// change is a previous value of dictionary entry, defined is false if the value
+// was undefined.
+type change struct {
+	dict, key string
+	value     interface{}
+	defined   bool
+}
+
+// dictionary contains previous values by key.
+type dictionary map[string]interface{}
 
 func newStorage() storage {
-	return make(map[string]interface{})
+	return storage{dictionaries: make(map[string]dictionary)}
 }
 
-func (s storage) save(key string, value interface{}) {
-	s[key] = value
+func (s storage) save(dict, key string, value interface{}) {
+	d, ok := s.dictionaries[dict]
+	if !ok {
+		d = make(dictionary)
+		s.dictionaries[dict] = d
+	}
+	if s.journal != nil {
+		previous, defined := d[key]
+		s.journal.changes = append(s.journal.changes, change{dict: dict, key: key, value: previous, defined: defined})
+	}
+	d[key] = value
 }
 
-func (s storage) load(key string) interface{} {
-	if value, ok := s[key]; ok {
+func (s storage) load(dict, key string) interface{} {
+	if value, ok := s.dictionaries[dict][key]; ok {
 		return value
 	}
 	return nil
 }
 
+// lookup returns value and true if the value is assigned or empty,
+// false means the value is undefined.
+func (s storage) lookup(dict, key string) (value interface{}, ok bool) {
+	value, ok = s.dictionaries[dict][key]
+	return
+}
+
+func (s *storage) saveTemplateID(id uint) {
+	s.templateID, s.templateIDDefined = id, true
+}
+
+// lookupTemplateID returns previous template identifier, false means it is undefined.
+func (s *storage) lookupTemplateID() (uint, bool) {
+	return s.templateID, s.templateIDDefined
+}
+
+// begin starts recording of changes made by a message, the changes of previous
+// message are discarded.
+func (s *storage) begin() {
+	if s.journal == nil {
+		s.journal = new(journal)
+	}
+	s.journal.changes = s.journal.changes[:0]
+	s.journal.templateID, s.journal.templateIDDefined = s.templateID, s.templateIDDefined
+}
+
+// rollback restores values changed since the last call of begin.
+func (s *storage) rollback() {
+	if s.journal == nil {
+		return
+	}
+
+	for i := len(s.journal.changes) - 1; i >= 0; i-- {
+		c := s.journal.changes[i]
+		if c.defined {
+			s.dictionaries[c.dict][c.key] = c.value
+		} else {
+			delete(s.dictionaries[c.dict], c.key)
+		}
+	}
+	s.journal.changes = s.journal.changes[:0]
+	s.templateID, s.templateIDDefined = s.journal.templateID, s.journal.templateIDDefined
+}
+
+// dictionaryName returns unique name of dictionary for template and application type.
+func dictionaryName(tpl *Template, dict, typeRef string) string {
+	switch dict {
+	case "", dictionaryGlobal:
+		return dictionaryGlobal
+	case dictionaryTemplate:
+		return dictionaryTemplate + ":" + strconv.Itoa(int(tpl.ID))
+	case dictionaryType:
+		return dictionaryType + ":" + typeRef
+	}
+	return dict
+}
+
+// reset removes all values of dictionary, so they become undefined.
+func (s *storage) reset(dict string) {
+	delete(s.dictionaries, dict)
+	if dict == dictionaryGlobal {
+		s.templateIDDefined = false
+	}
+}
+
+// resetKey makes value of key undefined.
+func (s storage) resetKey(dict, key string) {
+	delete(s.dictionaries[dict], key)
+}
+
+// resetAll makes all values of all dictionaries undefined.
+func (s *storage) resetAll() {
+	for dict := range s.dictionaries {
+		delete(s.dictionaries, dict)
+	}
+	s.templateIDDefined = false
+}
+
+// resetTemplate makes values used by instructions of template undefined.
+func (s *storage) resetTemplate(tpl *Template) {
+	s.reset(dictionaryName(tpl, dictionaryTemplate, ""))
+	s.resetInstructions(tpl.Instructions)
+}
+
+func (s storage) resetInstructions(instructions []*Instruction) {
+	for _, instruction := range instructions {
+		s.resetKey(instruction.dict, instruction.key)
+		s.resetInstructions(instruction.Instructions)
+	}
+}
+
+// resetDictionaries resets dictionaries by names, all dictionaries are reset if
+// names are not specified.
+func (s *storage) resetDictionaries(names []string) {
+	if len(names) == 0 {
+		s.resetAll()
+		return
+	}
+
+	for _, name := range names {
+		s.reset(name)
+	}
+}
@@ -5,26 +5,41 @@
 package fast
 
 import (
+	"encoding/hex"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	tagTemplate = "template"
-
-	tagString     = "string"
-	tagInt32      = "int32"
-	tagUint32     = "uInt32"
-	tagInt64      = "int64"
-	tagUint64     = "uInt64"
-	tagDecimal    = "decimal"
-	tagSequence   = "sequence"
-	tagGroup      = "group"
-	tagLength     = "length"
-	tagExponent   = "exponent"
-	tagMantissa   = "mantissa"
-	tagByteVector = "byteVector"
+	tagTemplates = "templates"
+	tagTemplate  = "template"
+	tagTypeRef   = "typeRef"
+
+	tagString      = "string"
+	tagInt32       = "int32"
+	tagUint32      = "uInt32"
+	tagInt64       = "int64"
+	tagUint64      = "uInt64"
+	tagDecimal     = "decimal"
+	tagSequence    = "sequence"
+	tagGroup       = "group"
+	tagLength      = "length"
+	tagExponent    = "exponent"
+	tagMantissa    = "mantissa"
+	tagByteVector  = "byteVector"
+	tagTemplateRef = "templateRef"
+	tagBoolean     = "boolean"
+	tagEnum        = "enum"
+	tagTimestamp   = "timestamp"
+	tagTime        = "time"
+	tagDate        = "date"
+	tagSet         = "set"
+	tagBitGroup    = "bitGroup"
+	tagElement     = "element"
 
 	tagIncrement = "increment"
 	tagConstant  = "constant"
@@ -33,15 +48,23 @@ const (
 	tagDelta     = "delta"
 	tagTail      = "tail"
 
-	attrID       = "id"
-	attrName     = "name"
-	attrPresence = "presence"
-	attrValue    = "value"
-	attrCharset  = "charset"
+	attrID         = "id"
+	attrName       = "name"
+	attrPresence   = "presence"
+	attrValue      = "value"
+	attrCharset    = "charset"
+	attrDictionary = "dictionary"
+	attrKey        = "key"
+	attrNs         = "ns"
+	attrTemplateNs = "templateNs"
+	attrUnit       = "unit"
 
 	valueMandatory = "mandatory"
 	valueOptional  = "optional"
 	valueUnicode   = "unicode"
+	valueASCII     = "ascii"
+
+	namespace = "http://www.fixprotocol.org/ns/fast/td/1.1"
 )
 
 // InstructionType specifies the basic encoding of the field.
@@ -70,6 +93,14 @@ const (
 	TypeByteVector
 	TypeSequence
 	TypeGroup
+	TypeTemplateRef // static reference is replaced by instructions of template, dynamic one has no name
+	TypeBoolean
+	TypeEnum
+	TypeTimestamp
+	TypeTime
+	TypeDate
+	TypeSet
+	TypeBitGroup // group of boolean fields encoded as bits of uInt64
 
 	OperatorNone InstructionOperator = iota
 	OperatorConstant
@@ -83,11 +114,34 @@ const (
 	PresenceOptional
 )
 
+// String returns name of operator as it is in XML template.
+func (o InstructionOperator) String() string {
+	switch o {
+	case OperatorConstant:
+		return tagConstant
+	case OperatorDelta:
+		return tagDelta
+	case OperatorDefault:
+		return tagDefault
+	case OperatorCopy:
+		return tagCopy
+	case OperatorIncrement:
+		return tagIncrement
+	case OperatorTail:
+		return tagTail
+	}
+	return "none"
+}
+
 // Template collect instructions for this template
 type Template struct {
 	ID           uint
 	Name         string
+	Dictionary   string // global, template, type or user defined dictionary name
+	TypeRef      string // application type of the template
 	Instructions []*Instruction
+
+	pMapSize int
 }
 
 func (t *Template) clone() (res Template) {
@@ -102,92 +156,252 @@ func cloneInstructions(data []*Instruction) []*Instruction {
 	}
 
 	res := make([]*Instruction, len(data))
-	copy(res, data)
-
-	for _, i := range res {
-		i.Instructions = cloneInstructions(i.Instructions)
+	for index, i := range data {
+		clone := *i
+		clone.Instructions = cloneInstructions(i.Instructions)
+		res[index] = &clone
 	}
 
 	return res
 }
 
 type xmlParser struct {
-	decoder *xml.Decoder
+	decoder    *xml.Decoder
+	dictionary string // dictionary attribute of templates element
+	template   string // name of current template
+	positions  map[interface{}]position
+	errs       TemplateErrors
 }
 
 // ParseXMLTemplate reads xml data from reader and return templates collection.
+// Static errors of all templates are returned as TemplateErrors with positions
+// of elements in XML.
 func ParseXMLTemplate(reader io.Reader) ([]*Template, error) {
 	return newXMLParser(reader).Parse()
 }
 
 func newXMLParser(reader io.Reader) *xmlParser {
-	return &xmlParser{decoder: xml.NewDecoder(reader)}
+	return &xmlParser{decoder: xml.NewDecoder(reader), positions: make(map[interface{}]position)}
 }
 
-func (p *xmlParser) Parse() (templates []*Template, err error) {
-	var token xml.Token
-	var template *Template
+func (p *xmlParser) Parse() ([]*Template, error) {
+	var templates []*Template
 	for {
-		token, err = p.decoder.Token()
+		token, err := p.decoder.Token()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return
+			return nil, p.fatal(err)
 		}
 
-		if start, ok := token.(xml.StartElement); ok && start.Name.Local == tagTemplate {
-			template, err = p.parseTemplate(&start)
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case tagTemplates:
+			p.checkAttrs(&start, "", attrDictionary)
+			p.dictionary = attrValueOf(&start, attrDictionary)
+		case tagTemplate:
+			template, err := p.parseTemplate(&start)
 			if err != nil {
-				return
+				return nil, p.fatal(err)
 			}
 			templates = append(templates, template)
+		default:
+			p.unknownElement(&start, "")
+			if err = p.skipElement(); err != nil {
+				return nil, p.fatal(err)
+			}
 		}
 	}
 
 	for _, tpl := range templates {
-		err = p.postProcessing(tpl.Instructions)
-		if err != nil {
-			break
+		if tpl.Dictionary == "" {
+			tpl.Dictionary = p.dictionary
 		}
 	}
 
+	if err := validate(templates, p.positions); err != nil {
+		p.errs = append(p.errs, err.(TemplateErrors)...)
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+
+	compile(templates)
+	return templates, nil
+}
+
+// Compile validates templates and prepares them for Encoder and Decoder: resolves
+// dictionaries and keys of instructions, replaces static template references and
+// counts presence map bits. Templates of ParseXMLTemplate are compiled already,
+// templates defined in Go code have to be compiled together with templates they
+// reference.
+func Compile(templates ...*Template) error {
+	if err := Validate(templates...); err != nil {
+		return err
+	}
+
+	compile(templates)
+	return nil
+}
+
+// compile prepares valid templates for Encoder and Decoder.
+func compile(templates []*Template) {
+	// dictionaries are resolved before static references are replaced, so the
+	// instructions of referenced template keep its dictionaries.
+	for _, tpl := range templates {
+		compileInstructions(tpl, tpl.Instructions, tpl.Dictionary, tpl.TypeRef)
+	}
+
+	// references are checked by validate
+	_ = resolveReferences(templates)
+
+	// presence maps are counted after static references are replaced, so the
+	// groups and sequences include bits of instructions of referenced templates.
+	for _, tpl := range templates {
+		tpl.pMapSize = countPMapBits(tpl.Instructions) + 1 // template id takes the first bit
+	}
+}
+
+// resolveReferences replaces static template references by instructions of
+// referenced templates.
+func resolveReferences(templates []*Template) (err error) {
+	byName := make(map[string]*Template, len(templates))
+	for _, tpl := range templates {
+		byName[tpl.Name] = tpl
+	}
+
+	for _, tpl := range templates {
+		tpl.Instructions, err = resolveInstructions(tpl.Instructions, byName, map[string]bool{tpl.Name: true})
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
-func (p *xmlParser) postProcessing(instructions []*Instruction) (err error) {
-	for _, item := range instructions {
-		if !item.isValid() {
-			return ErrS2
+func resolveInstructions(
+	instructions []*Instruction,
+	byName map[string]*Template,
+	path map[string]bool,
+) ([]*Instruction, error) {
+	res := make([]*Instruction, 0, len(instructions))
+	for _, instruction := range instructions {
+		if instruction.Type != TypeTemplateRef || instruction.Name == "" {
+			inner, err := resolveInstructions(instruction.Instructions, byName, path)
+			if err != nil {
+				return nil, err
+			}
+			if instruction.Instructions != nil {
+				instruction.Instructions = inner
+			}
+			res = append(res, instruction)
+			continue
 		}
 
-		item.key = strconv.Itoa(int(item.ID)) + ":" +
-			item.Name + ":" +
-			strconv.Itoa(int(item.Type))
+		tpl, ok := byName[instruction.Name]
+		if !ok {
+			return nil, ErrD8
+		}
 
-		err = p.postProcessing(item.Instructions)
+		// template can not include itself
+		if path[tpl.Name] {
+			return nil, ErrS1
+		}
+
+		path[tpl.Name] = true
+		inner, err := resolveInstructions(cloneInstructions(tpl.Instructions), byName, path)
+		delete(path, tpl.Name)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		res = append(res, inner...)
+	}
+	return res, nil
+}
+
+// compileInstructions resolves dictionaries and keys of instructions.
+func compileInstructions(tpl *Template, instructions []*Instruction, dictionary, typeRef string) {
+	for _, item := range instructions {
+		dict, ref := dictionary, typeRef
+		if item.Dictionary != "" {
+			dict = item.Dictionary
+		}
+		if item.TypeRef != "" {
+			ref = item.TypeRef
+		}
+
+		item.dict = dictionaryName(tpl, dict, ref)
+		item.key = item.Key
+		if item.key == "" {
+			item.key = item.Name
+		}
+
+		// exponent and mantissa of decimal have the same name, but separate entries
+		if item.Key == "" && item.Type == TypeExponent {
+			item.key += "." + tagExponent
+		}
+		if item.Key == "" && item.Type == TypeMantissa {
+			item.key += "." + tagMantissa
+		}
+
+		compileInstructions(tpl, item.Instructions, dict, ref)
 
 		if item.Type != TypeSequence && item.Type != TypeGroup {
 			continue
 		}
 
 		for _, instruction := range item.Instructions {
-			if instruction.hasPmapBit() {
-				item.pMapSize++
+			// length of sequence without name uses the key of the sequence
+			if instruction.Type == TypeLength && instruction.Key == "" && instruction.Name == "" {
+				instruction.key = item.key + ".length"
 			}
 		}
 	}
+}
 
+// countPMapBits sets sizes of presence maps of groups and sequences and returns
+// count of bits which instructions take in presence map of their segment.
+func countPMapBits(instructions []*Instruction) (bits int) {
+	for _, item := range instructions {
+		switch {
+		case item.Type == TypeGroup:
+			item.pMapSize = countPMapBits(item.Instructions)
+			if item.isOptional() {
+				bits++
+			}
+		case item.Type == TypeSequence:
+			// length is encoded in the segment of sequence
+			item.pMapSize = countPMapBits(item.Instructions[1:])
+			bits += countPMapBits(item.Instructions[:1])
+		case item.Type == TypeDecimal && len(item.Instructions) > 0:
+			bits += countPMapBits(item.Instructions)
+		case item.hasPmapBit():
+			bits++
+		}
+	}
 	return
 }
 
 func (p *xmlParser) parseTemplate(token *xml.StartElement) (*Template, error) {
-	template, err := newTemplate(token)
-	if err != nil {
-		return nil, err
+	template := &Template{}
+	p.template = attrValueOf(token, attrName)
+	p.positions[template] = p.pos()
+	p.checkAttrs(token, "", attrName, attrID, attrDictionary)
+
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case attrName:
+			template.Name = attr.Value
+		case attrID:
+			template.ID = p.parseID(attr.Value, "")
+		case attrDictionary:
+			template.Dictionary = attr.Value
+		}
 	}
 
 	for {
@@ -196,53 +410,41 @@ func (p *xmlParser) parseTemplate(token *xml.StartElement) (*Template, error) {
 			return nil, err
 		}
 
-		if start, ok := token.(xml.StartElement); ok {
-			instruction, err := p.parseInstruction(&start)
-			if err != nil {
-				return nil, err
-			}
-			template.Instructions = append(template.Instructions, instruction)
-		}
-
 		if _, ok := token.(xml.EndElement); ok {
 			break
 		}
-	}
 
-	return template, nil
-}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
 
-func (p *xmlParser) parseDecimalInstructionOrOperator(token *xml.StartElement, instruction *Instruction) error {
-	inner, err := newInstruction(token)
-	if err != nil {
-		return err
-	}
+		if start.Name.Local == tagTypeRef {
+			template.TypeRef, err = p.parseTypeRef(&start)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-	if inner.Type != TypeNull {
-		inner, err := p.parseInstruction(token)
+		instruction, err := p.parseInstruction(&start)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		inner.ID = instruction.ID
-		inner.Name = instruction.Name
-
-		// If the decimal has optional presence, the exponent field is treated as on optional
-		//  integer field and the mantissa field is treated as a mandatory integer field.
-		if inner.Type == TypeExponent && instruction.Presence == PresenceOptional {
-			inner.Presence = instruction.Presence
+		if instruction != nil {
+			template.Instructions = append(template.Instructions, instruction)
 		}
-		instruction.Instructions = append(instruction.Instructions, inner)
-	} else {
-		err = p.parseOperation(token, instruction)
 	}
 
-	return err
+	return template, nil
 }
 
+// parseInstruction returns nil instruction for unknown element.
 func (p *xmlParser) parseInstruction(token *xml.StartElement) (*Instruction, error) {
-	instruction, err := newInstruction(token)
-	if err != nil {
-		return nil, err
+	instruction := p.newInstruction(token)
+	if instruction == nil {
+		p.unknownElement(token, "")
+		return nil, p.skipElement()
 	}
 
 	for {
@@ -251,37 +453,102 @@ func (p *xmlParser) parseInstruction(token *xml.StartElement) (*Instruction, err
 			return nil, err
 		}
 
-		if start, ok := token.(xml.StartElement); ok {
-			switch instruction.Type {
-			case TypeSequence, TypeGroup:
-				inner, err := p.parseInstruction(&start)
-				if err != nil {
-					return nil, err
-				}
+		if _, ok := token.(xml.EndElement); ok {
+			break
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch instruction.Type {
+		case TypeSequence, TypeGroup:
+			if start.Name.Local == tagTypeRef {
+				instruction.TypeRef, err = p.parseTypeRef(&start)
+				break
+			}
+
+			var inner *Instruction
+			inner, err = p.parseInstruction(&start)
+			if inner != nil {
 				instruction.Instructions = append(instruction.Instructions, inner)
-				if inner.Type == TypeLength && instruction.Presence == PresenceOptional {
-					inner.Presence = PresenceOptional
+			}
+		case TypeDecimal:
+			if start.Name.Local != tagExponent && start.Name.Local != tagMantissa {
+				err = p.parseOperation(&start, instruction)
+				break
+			}
+
+			var inner *Instruction
+			inner, err = p.parseInstruction(&start)
+			for _, item := range instruction.Instructions {
+				if inner != nil && item.Type == inner.Type {
+					p.errorf(instruction.Name, ErrS1, "duplicate "+start.Name.Local)
+					inner = nil
 				}
-			case TypeDecimal:
-				err = p.parseDecimalInstructionOrOperator(&start, instruction)
-			default:
+			}
+			if inner != nil {
+				instruction.Instructions = append(instruction.Instructions, inner)
+			}
+		case TypeEnum, TypeSet:
+			if start.Name.Local != tagElement {
+				err = p.parseOperation(&start, instruction)
+				break
+			}
+			p.checkAttrs(&start, instruction.Name, attrName)
+			instruction.Elements = append(instruction.Elements, attrValueOf(&start, attrName))
+			err = p.skipElement()
+		case TypeBitGroup:
+			var inner *Instruction
+			inner, err = p.parseInstruction(&start)
+			if inner != nil {
+				instruction.Instructions = append(instruction.Instructions, inner)
+			}
+		case TypeTemplateRef, TypeExponent, TypeMantissa, TypeLength:
+			if isOperator(start.Name.Local) && instruction.Type != TypeTemplateRef {
 				err = p.parseOperation(&start, instruction)
+				break
 			}
+			p.unknownElement(&start, instruction.Name)
+			err = p.skipElement()
+		case TypeASCIIString, TypeUnicodeString, TypeByteVector:
+			if start.Name.Local == tagLength {
+				// length of string or byte vector has no instruction
+				p.checkAttrs(&start, instruction.Name, attrName, attrID)
+				err = p.skipElement()
+				break
+			}
+			err = p.parseOperation(&start, instruction)
+		default:
+			err = p.parseOperation(&start, instruction)
 		}
 
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		if _, ok := token.(xml.EndElement); ok {
-			break
-		}
+	switch {
+	case instruction.Type == TypeSequence:
+		completeSequence(instruction)
+	case instruction.Type == TypeDecimal && len(instruction.Instructions) > 0:
+		completeDecimal(instruction)
 	}
 
 	return instruction, nil
 }
 
 func (p *xmlParser) parseOperation(token *xml.StartElement, instruction *Instruction) error {
+	if !isOperator(token.Name.Local) {
+		p.unknownElement(token, instruction.Name)
+		return p.skipElement()
+	}
+
+	if instruction.Operator != OperatorNone {
+		p.errorf(instruction.Name, ErrS1, "multiple operators")
+	}
+
 	switch token.Name.Local {
 	case tagConstant:
 		instruction.Operator = OperatorConstant
@@ -293,46 +560,50 @@ func (p *xmlParser) parseOperation(token *xml.StartElement, instruction *Instruc
 		instruction.Operator = OperatorDelta
 	case tagIncrement:
 		instruction.Operator = OperatorIncrement
-	default:
-		instruction.Operator = OperatorNone
+	case tagTail:
+		instruction.Operator = OperatorTail
+	}
+
+	p.checkAttrs(token, instruction.Name, attrValue, attrDictionary, attrKey)
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case attrDictionary:
+			instruction.Dictionary = attr.Value
+		case attrKey:
+			instruction.Key = attr.Value
+		}
 	}
 
 	var err error
 	instruction.Value, err = newValue(token, instruction.Type)
 	if err != nil {
-		return ErrS3
+		p.errorf(instruction.Name, ErrS3, fmt.Sprintf("invalid initial value %q", attrValueOf(token, attrValue)))
 	}
 
-	if instruction.Operator == OperatorConstant && instruction.Value == nil {
-		return ErrS4
-	}
-
-	if instruction.Presence == PresenceMandatory &&
-		instruction.Operator == OperatorDefault &&
-		instruction.Value == nil {
-		return ErrS5
-	}
-
-	for {
-		token, err := p.decoder.Token()
-		if err != nil {
-			return err
-		}
+	return p.skipElement()
+}
 
-		if _, ok := token.(xml.EndElement); ok {
-			break
-		}
-	}
+// parseTypeRef returns name of application type.
+func (p *xmlParser) parseTypeRef(token *xml.StartElement) (string, error) {
+	p.checkAttrs(token, "", attrName)
+	return attrValueOf(token, attrName), p.skipElement()
+}
 
-	return nil
+// skipElement reads tokens until the end of current element.
+func (p *xmlParser) skipElement() error {
+	return p.decoder.Skip()
 }
 
-func newInstruction(token *xml.StartElement) (*Instruction, error) {
+// newInstruction returns nil for unknown element.
+func (p *xmlParser) newInstruction(token *xml.StartElement) *Instruction {
 	instruction := &Instruction{Operator: OperatorNone, Presence: PresenceMandatory}
 
+	// attributes of instruction besides name
+	var attrs []string
 	switch token.Name.Local {
 	case tagString:
 		instruction.Type = TypeASCIIString
+		attrs = []string{attrID, attrPresence, attrDictionary, attrCharset}
 	case tagInt32:
 		instruction.Type = TypeInt32
 	case tagInt64:
@@ -349,60 +620,222 @@ func newInstruction(token *xml.StartElement) (*Instruction, error) {
 		instruction.Type = TypeGroup
 	case tagLength:
 		instruction.Type = TypeLength
+		attrs = []string{attrID, attrDictionary}
 	case tagExponent:
 		instruction.Type = TypeExponent
+		attrs = []string{}
 	case tagMantissa:
 		instruction.Type = TypeMantissa
+		attrs = []string{}
 	case tagByteVector:
 		instruction.Type = TypeByteVector
+	case tagTemplateRef:
+		instruction.Type = TypeTemplateRef
+		attrs = []string{}
+	case tagBoolean:
+		instruction.Type = TypeBoolean
+	case tagEnum:
+		instruction.Type = TypeEnum
+	case tagTimestamp:
+		instruction.Type = TypeTimestamp
+		attrs = []string{attrID, attrPresence, attrDictionary, attrUnit}
+	case tagTime:
+		instruction.Type = TypeTime
+		attrs = []string{attrID, attrPresence, attrDictionary, attrUnit}
+	case tagDate:
+		instruction.Type = TypeDate
+	case tagSet:
+		instruction.Type = TypeSet
+	case tagBitGroup:
+		instruction.Type = TypeBitGroup
 	default:
-		instruction.Type = TypeNull
+		return nil
+	}
+	if attrs == nil {
+		attrs = []string{attrID, attrPresence, attrDictionary}
 	}
+	if instruction.Type != TypeExponent && instruction.Type != TypeMantissa {
+		attrs = append(attrs, attrName)
+	}
+
+	instruction.Name = attrValueOf(token, attrName)
+	p.positions[instruction] = p.pos()
+	p.checkAttrs(token, instruction.Name, attrs...)
 
 	for _, attr := range token.Attr {
 		switch attr.Name.Local {
-		case attrName:
-			instruction.Name = attr.Value
 		case attrID:
-			id, err := strconv.Atoi(attr.Value)
-			if err != nil {
-				return nil, err
-			}
-			instruction.ID = uint(id)
+			instruction.ID = p.parseID(attr.Value, instruction.Name)
 		case attrPresence:
-			if attr.Value == valueMandatory {
+			switch attr.Value {
+			case valueMandatory:
 				instruction.Presence = PresenceMandatory
-			}
-			if attr.Value == valueOptional {
+			case valueOptional:
 				instruction.Presence = PresenceOptional
+			default:
+				p.errorf(instruction.Name, ErrS1, fmt.Sprintf("invalid presence %q", attr.Value))
 			}
 		case attrCharset:
-			if attr.Value == valueUnicode {
+			switch attr.Value {
+			case valueUnicode:
 				instruction.Type = TypeUnicodeString
+			case valueASCII:
+			default:
+				p.errorf(instruction.Name, ErrS1, fmt.Sprintf("invalid charset %q", attr.Value))
+			}
+		case attrDictionary:
+			instruction.Dictionary = attr.Value
+		case attrUnit:
+			var ok bool
+			if instruction.Unit, ok = units[attr.Value]; !ok {
+				p.errorf(instruction.Name, ErrS1, fmt.Sprintf("invalid unit %q", attr.Value))
 			}
 		}
 	}
 
-	return instruction, nil
+	return instruction
 }
 
-func newTemplate(token *xml.StartElement) (*Template, error) {
-	template := &Template{}
+// parseID returns id of template or instruction, invalid id is reported.
+func (p *xmlParser) parseID(value, name string) uint {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		p.errorf(name, ErrS1, fmt.Sprintf("invalid id %q", value))
+	}
+	return uint(id)
+}
 
+// checkAttrs reports attributes of element, which are not allowed. Namespace
+// attributes are allowed for all elements.
+func (p *xmlParser) checkAttrs(token *xml.StartElement, name string, allowed ...string) {
 	for _, attr := range token.Attr {
-		switch attr.Name.Local {
-		case attrName:
-			template.Name = attr.Value
-		case attrID:
-			id, err := strconv.Atoi(attr.Value)
-			if err != nil {
-				return nil, err
-			}
-			template.ID = uint(id)
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" ||
+			attr.Name.Local == attrNs || attr.Name.Local == attrTemplateNs {
+			continue
+		}
+
+		ok := false
+		for _, item := range allowed {
+			ok = ok || attr.Name.Local == item
+		}
+		if !ok {
+			p.errorf(name, ErrS1, "unknown attribute "+attr.Name.Local+" of "+token.Name.Local)
+		}
+	}
+}
+
+func (p *xmlParser) unknownElement(token *xml.StartElement, name string) {
+	p.errorf(name, ErrS1, "unknown element "+token.Name.Local)
+}
+
+// errorf reports error at the current position of decoder.
+func (p *xmlParser) errorf(name string, err error, msg string) {
+	pos := p.pos()
+	p.errs = append(p.errs, &TemplateError{
+		Line:     pos.line,
+		Column:   pos.column,
+		Template: p.template,
+		Path:     name,
+		Msg:      msg,
+		Err:      err,
+	})
+}
+
+// fatal returns reported errors and err, which stops parsing. Syntax error of
+// XML is reported as ErrS1.
+func (p *xmlParser) fatal(err error) error {
+	e, ok := err.(*xml.SyntaxError)
+	if !ok {
+		return err
+	}
+	p.errs = append(p.errs, &TemplateError{Line: e.Line, Template: p.template, Msg: e.Msg, Err: ErrS1})
+	return p.errs
+}
+
+func (p *xmlParser) pos() position {
+	line, column := p.decoder.InputPos()
+	return position{line: line, column: column}
+}
+
+func isOperator(tag string) bool {
+	switch tag {
+	case tagConstant, tagDefault, tagCopy, tagDelta, tagIncrement, tagTail:
+		return true
+	}
+	return false
+}
+
+// completeSequence adds length without name to sequence without length. Length of
+// optional sequence is optional.
+func completeSequence(sequence *Instruction) {
+	hasLength := false
+	for _, inner := range sequence.Instructions {
+		if inner.Type != TypeLength {
+			continue
+		}
+		hasLength = true
+		if sequence.isOptional() {
+			inner.Presence = PresenceOptional
 		}
 	}
 
-	return template, nil
+	if !hasLength {
+		length := &Instruction{Type: TypeLength, Operator: OperatorNone, Presence: sequence.Presence}
+		sequence.Instructions = append([]*Instruction{length}, sequence.Instructions...)
+	}
+}
+
+// completeDecimal orders exponent and mantissa of decimal with individual operators,
+// the missing one has no operator.
+func completeDecimal(decimal *Instruction) {
+	var exponent, mantissa *Instruction
+	for _, inner := range decimal.Instructions {
+		if inner.Type == TypeExponent && exponent == nil {
+			exponent = inner
+		}
+		if inner.Type == TypeMantissa && mantissa == nil {
+			mantissa = inner
+		}
+	}
+	if exponent == nil {
+		exponent = &Instruction{Type: TypeExponent, Operator: OperatorNone}
+	}
+	if mantissa == nil {
+		mantissa = &Instruction{Type: TypeMantissa, Operator: OperatorNone}
+	}
+
+	for _, inner := range []*Instruction{exponent, mantissa} {
+		inner.ID = decimal.ID
+		inner.Name = decimal.Name
+		inner.Presence = PresenceMandatory
+	}
+
+	// If the decimal has optional presence, the exponent field is treated as on optional
+	//  integer field and the mantissa field is treated as a mandatory integer field.
+	if decimal.isOptional() {
+		exponent.Presence = PresenceOptional
+	}
+	decimal.Instructions = []*Instruction{exponent, mantissa}
+}
+
+func attrValueOf(token *xml.StartElement, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// timeLayout returns layout of initial value of timestamp, time or date.
+func timeLayout(typ InstructionType) string {
+	switch typ {
+	case TypeTime:
+		return "15:04:05.999999999"
+	case TypeDate:
+		return "2006-01-02"
+	}
+	return time.RFC3339Nano
 }
 
 func newValue(token *xml.StartElement, typ InstructionType) (value interface{}, err error) {
@@ -413,7 +846,7 @@ func newValue(token *xml.StartElement, typ InstructionType) (value interface{},
 				value = attr.Value
 			case TypeUint64:
 				value, err = strconv.ParseUint(attr.Value, 10, 64)
-			case TypeUint32:
+			case TypeUint32, TypeLength:
 				value, err = strconv.ParseUint(attr.Value, 10, 32)
 				value = uint32(value.(uint64))
 			case TypeInt64, TypeMantissa:
@@ -421,9 +854,260 @@ func newValue(token *xml.StartElement, typ InstructionType) (value interface{},
 			case TypeInt32, TypeExponent:
 				value, err = strconv.ParseInt(attr.Value, 10, 32)
 				value = int32(value.(int64))
+			case TypeDecimal:
+				value, err = ParseDecimal(attr.Value)
+			case TypeByteVector:
+				// byte vector is in hexadecimal form, whitespaces are ignored
+				value, err = hex.DecodeString(strings.Join(strings.Fields(attr.Value), ""))
+			case TypeBoolean:
+				value, err = strconv.ParseBool(attr.Value)
+			case TypeEnum:
+				value = attr.Value
+			case TypeSet:
+				value = strings.Fields(attr.Value)
+			case TypeTimestamp, TypeTime, TypeDate:
+				value, err = time.ParseInLocation(timeLayout(typ), attr.Value, time.UTC)
+				if err == nil && typ == TypeTime {
+					t := value.(time.Time)
+					value = time.Date(1970, 1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+				}
 			}
 			return
 		}
 	}
 	return
 }
+
+type xmlWriter struct {
+	encoder *xml.Encoder
+	err     error
+}
+
+// WriteXMLTemplate writes templates to writer in XML syntax of FAST 1.1. Static template
+// references are written as instructions of referenced templates, because they are
+// replaced by ParseXMLTemplate.
+func WriteXMLTemplate(writer io.Writer, templates []*Template) error {
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+
+	w := &xmlWriter{encoder: xml.NewEncoder(writer)}
+	w.encoder.Indent("", "    ")
+
+	w.start(tagTemplates, attr("xmlns", namespace))
+	for _, tpl := range templates {
+		w.writeTemplate(tpl)
+	}
+	w.end(tagTemplates)
+
+	if w.err == nil {
+		w.err = w.encoder.Flush()
+	}
+	if w.err != nil {
+		return w.err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+func (w *xmlWriter) writeTemplate(tpl *Template) {
+	attrs := []xml.Attr{
+		attr(attrName, tpl.Name),
+		attr(attrID, strconv.FormatUint(uint64(tpl.ID), 10)),
+	}
+	if tpl.Dictionary != "" {
+		attrs = append(attrs, attr(attrDictionary, tpl.Dictionary))
+	}
+
+	w.start(tagTemplate, attrs...)
+	w.writeTypeRef(tpl.TypeRef)
+	for _, instruction := range tpl.Instructions {
+		w.writeInstruction(instruction)
+	}
+	w.end(tagTemplate)
+}
+
+func (w *xmlWriter) writeInstruction(instruction *Instruction) {
+	if instruction.Type == TypeNull {
+		return // unknown element is not kept by parser
+	}
+	tag := typeTag(instruction.Type)
+
+	var attrs []xml.Attr
+	switch instruction.Type {
+	case TypeExponent, TypeMantissa:
+		// name, id and presence are the same as of decimal
+	default:
+		if instruction.Name != "" {
+			attrs = append(attrs, attr(attrName, instruction.Name))
+		}
+		if instruction.ID != 0 {
+			attrs = append(attrs, attr(attrID, strconv.FormatUint(uint64(instruction.ID), 10)))
+		}
+		if instruction.isOptional() && instruction.Type != TypeLength {
+			attrs = append(attrs, attr(attrPresence, valueOptional))
+		}
+	}
+	if instruction.Type == TypeUnicodeString {
+		attrs = append(attrs, attr(attrCharset, valueUnicode))
+	}
+	if instruction.Operator == OperatorNone && instruction.Dictionary != "" {
+		attrs = append(attrs, attr(attrDictionary, instruction.Dictionary))
+	}
+	if instruction.Unit != 0 {
+		attrs = append(attrs, attr(attrUnit, unitName(instruction.Unit)))
+	}
+
+	w.start(tag, attrs...)
+	switch instruction.Type {
+	case TypeEnum, TypeSet:
+		for _, name := range instruction.Elements {
+			w.start(tagElement, attr(attrName, name))
+			w.end(tagElement)
+		}
+		w.writeOperator(instruction)
+	case TypeBitGroup:
+		for _, inner := range instruction.Instructions {
+			w.writeInstruction(inner)
+		}
+	case TypeSequence, TypeGroup:
+		w.writeTypeRef(instruction.TypeRef)
+		for _, inner := range instruction.Instructions {
+			w.writeInstruction(inner)
+		}
+	case TypeDecimal:
+		if len(instruction.Instructions) > 0 {
+			for _, inner := range instruction.Instructions {
+				w.writeInstruction(inner)
+			}
+			break
+		}
+		w.writeOperator(instruction)
+	default:
+		w.writeOperator(instruction)
+	}
+	w.end(tag)
+}
+
+func (w *xmlWriter) writeOperator(instruction *Instruction) {
+	if instruction.Operator == OperatorNone {
+		return
+	}
+
+	var attrs []xml.Attr
+	if instruction.Value != nil {
+		attrs = append(attrs, attr(attrValue, formatValue(instruction.Type, instruction.Value)))
+	}
+	if instruction.Dictionary != "" {
+		attrs = append(attrs, attr(attrDictionary, instruction.Dictionary))
+	}
+	if instruction.Key != "" {
+		attrs = append(attrs, attr(attrKey, instruction.Key))
+	}
+
+	tag := instruction.Operator.String()
+	w.start(tag, attrs...)
+	w.end(tag)
+}
+
+func (w *xmlWriter) writeTypeRef(name string) {
+	if name == "" {
+		return
+	}
+	w.start(tagTypeRef, attr(attrName, name))
+	w.end(tagTypeRef)
+}
+
+func (w *xmlWriter) start(tag string, attrs ...xml.Attr) {
+	w.token(xml.StartElement{Name: xml.Name{Local: tag}, Attr: attrs})
+}
+
+func (w *xmlWriter) end(tag string) {
+	w.token(xml.EndElement{Name: xml.Name{Local: tag}})
+}
+
+func (w *xmlWriter) token(token xml.Token) {
+	if w.err == nil {
+		w.err = w.encoder.EncodeToken(token)
+	}
+}
+
+func attr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+// typeTag returns name of element of instruction type.
+func typeTag(typ InstructionType) string {
+	switch typ {
+	case TypeUint32:
+		return tagUint32
+	case TypeInt32:
+		return tagInt32
+	case TypeUint64:
+		return tagUint64
+	case TypeInt64:
+		return tagInt64
+	case TypeLength:
+		return tagLength
+	case TypeExponent:
+		return tagExponent
+	case TypeMantissa:
+		return tagMantissa
+	case TypeDecimal:
+		return tagDecimal
+	case TypeASCIIString, TypeUnicodeString:
+		return tagString
+	case TypeByteVector:
+		return tagByteVector
+	case TypeSequence:
+		return tagSequence
+	case TypeGroup:
+		return tagGroup
+	case TypeBoolean:
+		return tagBoolean
+	case TypeEnum:
+		return tagEnum
+	case TypeTimestamp:
+		return tagTimestamp
+	case TypeTime:
+		return tagTime
+	case TypeDate:
+		return tagDate
+	case TypeSet:
+		return tagSet
+	case TypeBitGroup:
+		return tagBitGroup
+	}
+	return tagTemplateRef
+}
+
+// formatValue returns initial value as it is in XML template.
+func formatValue(typ InstructionType, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, " ")
+	case time.Time:
+		return v.Format(timeLayout(typ))
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case Decimal:
+		if v.Exponent > 0 {
+			return strconv.FormatInt(v.Mantissa, 10) + "E" + strconv.Itoa(int(v.Exponent))
+		}
+		// fixed point keeps trailing zeros of mantissa
+		return v.ToDecimal().StringFixed(-v.Exponent)
+	case []byte:
+		return hex.EncodeToString(v)
+	}
+	return fmt.Sprint(value)
+}
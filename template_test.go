@@ -1,7 +1,11 @@
 package fast_test
 
 import (
+	"bytes"
+	"errors"
 	"github.com/co11ter/goFAST"
+	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -11,9 +15,9 @@ var (
 <?xml version="1.0" encoding="UTF-8"?>
 <templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
 	<template name="Test" id="1" xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
-		<string name="Type" id="15">
-			<delta/>
-		</string>
+		<int32 name="Type" id="15">
+			<tail/>
+		</int32>
 	</template>
 </templates>`
 
@@ -46,18 +50,282 @@ var (
 		</string>
 	</template>
 </templates>`
+
+	xmlWrite = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1" dictionary="template">
+	<template name="Test" id="1">
+		<typeRef name="Quote"/>
+		<string name="Name" id="1" charset="unicode"><default value="a &amp; b" key="name"/></string>
+		<int64 name="Delta" id="2" presence="optional"><delta dictionary="global"/></int64>
+		<decimal name="Price" id="3" presence="optional">
+			<exponent><copy value="-2" dictionary="prices"/></exponent>
+			<mantissa><delta key="mantissa"/></mantissa>
+		</decimal>
+		<sequence name="Entries" presence="optional">
+			<typeRef name="Entry"/>
+			<length name="NoEntries" id="4"><increment value="1"/></length>
+			<uInt32 name="Size" id="5" dictionary="sizes"/>
+			<group name="Inner">
+				<byteVector name="Data" id="6" presence="optional"><tail/></byteVector>
+			</group>
+		</sequence>
+		<decimal name="Rate" id="7"><constant value="0.010"/></decimal>
+		<decimal name="Scale" id="8"><default value="15E2"/></decimal>
+		<byteVector name="Magic" id="9"><default value="0a 0B ff"/></byteVector>
+		<templateRef/>
+	</template>
+</templates>`
+
+	xmlValues = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Values" id="1">
+		<decimal name="Rate" id="1"><constant value="0.01"/></decimal>
+		<decimal name="Price" id="2"><delta value="1.25"/></decimal>
+		<decimal name="Size" id="3" presence="optional"><default value="100"/></decimal>
+		<byteVector name="Magic" id="4"><default value="CAFE"/></byteVector>
+		<sequence name="Pair">
+			<length name="NoPair" id="5"><constant value="2"/></length>
+			<uInt32 name="Value" id="6"/>
+		</sequence>
+	</template>
+</templates>`
+
+	xmlErrs = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Test" id="1" xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+		<int32 name="Type" id="15" size="4">
+			<constant value="abc"/>
+		</int32>
+		<float name="Unknown"/>
+		<uInt32 name="Price" id="16"><copy/><tail/></uInt32>
+		<string name="Type" id="17"/>
+	</template>
+	<template name="Other" id="1"><templateRef name="Unknown"/>
+		<sequence name="Entries"><uInt32 name="Data" id="2"/>
+			<length name="Length" id="3"/>
+		</sequence>
+	</template>
+</templates>`
+
+	xmlImplicitLength = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Test" id="1">
+		<sequence name="Entries" presence="optional">
+			<uInt32 name="Data" id="2"/>
+		</sequence>
+	</template>
+</templates>`
+
+	xmlErrDecimal = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Test" id="1">
+		<decimal name="Price" id="1"><constant value="1.2.3"/></decimal>
+		<byteVector name="Data" id="2"><constant value="xyz"/></byteVector>
+	</template>
+</templates>`
+
+	xmlErrD8 = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Test" id="1" xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+		<templateRef name="Unknown"/>
+	</template>
+</templates>`
+
+	xmlGroupReference = `
+<?xml version="1.0" encoding="UTF-8"?>
+<templates xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+	<template name="Ref" id="1" xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+		<uInt32 name="RefField" id="1"><copy/></uInt32>
+	</template>
+	<template name="Outer" id="2" xmlns="http://www.fixprotocol.org/ns/fast/td/1.1">
+		<group name="Group" id="2">
+			<templateRef name="Ref"/>
+		</group>
+	</template>
+</templates>`
 )
 
+type groupReferenceMessage struct {
+	TemplateID uint `fast:"*"`
+	Group      struct {
+		RefField uint32
+	}
+}
+
 func TestParseXMLTemplate(t *testing.T) {
 	checkErr(t, xmlErrS2, fast.ErrS2)
 	checkErr(t, xmlErrS3, fast.ErrS3)
 	checkErr(t, xmlErrS4, fast.ErrS4)
 	checkErr(t, xmlErrS5, fast.ErrS5)
+	checkErr(t, xmlErrD8, fast.ErrD8)
+	checkErr(t, xmlErrDecimal, fast.ErrS3)
 }
 
 func checkErr(t *testing.T, data string, err error) {
 	_, got := fast.ParseXMLTemplate(strings.NewReader(data))
-	if got != err {
+	if !errors.Is(got, err) {
 		t.Fatal("not found err: '", err, "' got '", got, "'")
 	}
 }
+
+func TestParseGroupReference(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlGroupReference))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := groupReferenceMessage{TemplateID: 2}
+	msg.Group.RefField = 5
+
+	// presence map of group has to contain bit of copy field of referenced template
+	expect := []byte{0xc0, 0x82, 0xc0, 0x85}
+
+	buf := &bytes.Buffer{}
+	err = fast.NewEncoder(buf, tpls...).Encode(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+}
+
+func TestParseXMLTemplateErrors(t *testing.T) {
+	_, err := fast.ParseXMLTemplate(strings.NewReader(xmlErrs))
+	errs, ok := err.(fast.TemplateErrors)
+	if !ok {
+		t.Fatal("expected TemplateErrors, got", err)
+	}
+
+	expect := []struct {
+		line int
+		path string
+		err  error
+	}{
+		{line: 5, path: "Type", err: fast.ErrS1},            // unknown attribute
+		{line: 6, path: "Type", err: fast.ErrS3},            // invalid initial value
+		{line: 8, path: "", err: fast.ErrS1},                // unknown element
+		{line: 9, path: "Price", err: fast.ErrS1},           // multiple operators
+		{line: 12, path: "", err: fast.ErrS1},               // duplicate template id
+		{line: 9, path: "Price", err: fast.ErrS2},           // tail of integer
+		{line: 10, path: "Type", err: fast.ErrS1},           // duplicate field name
+		{line: 12, path: "Unknown", err: fast.ErrD8},        // unknown static reference
+		{line: 13, path: "Entries", err: fast.ErrS1},        // sequence has no length
+		{line: 14, path: "Entries.Length", err: fast.ErrS1}, // length is not the first
+	}
+	if len(errs) != len(expect) {
+		t.Fatal("expected", len(expect), "errors, got", len(errs), errs)
+	}
+	for i, e := range expect {
+		if errs[i].Line != e.line || errs[i].Path != e.path || !errors.Is(errs[i], e.err) {
+			t.Fatal("unexpected error", i, errs[i])
+		}
+	}
+	if !errors.Is(err, fast.ErrD8) {
+		t.Fatal("expected ErrD8 in", err)
+	}
+}
+
+func TestInitialValues(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []interface{}{
+		fast.Decimal{Mantissa: 1, Exponent: -2},
+		fast.Decimal{Mantissa: 125, Exponent: -2},
+		fast.Decimal{Mantissa: 100},
+		[]byte{0xca, 0xfe},
+	}
+	for i, value := range expect {
+		if current := tpls[0].Instructions[i].Value; !reflect.DeepEqual(current, value) {
+			t.Fatal("expected", value, "got", current)
+		}
+	}
+	if value := tpls[0].Instructions[4].Instructions[0].Value; value != uint32(2) {
+		t.Fatal("expected length 2, got", value)
+	}
+
+	type values struct {
+		TemplateID uint `fast:"*"`
+		Rate       float64
+		Price      fast.Decimal
+		Size       *float64
+		Magic      []byte
+		Pair       []struct {
+			Value uint32
+		}
+	}
+	size := float64(100)
+	msg := values{
+		TemplateID: 1,
+		Rate:       0.01,
+		Price:      fast.Decimal{Mantissa: 126, Exponent: -2},
+		Size:       &size,
+		Magic:      []byte{0xca, 0xfe},
+		Pair:       []struct{ Value uint32 }{{Value: 1}, {Value: 2}},
+	}
+
+	var buf bytes.Buffer
+	if err = fast.NewEncoder(&buf, tpls...).Encode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	// pmap, tid, price delta: exponent 0, mantissa +1, pair values
+	if expect := []byte{0xc0, 0x81, 0x80, 0x81, 0x81, 0x82}; !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("data is not equal. current: %x expected: %x", buf.Bytes(), expect)
+	}
+
+	var decoded values
+	if err = fast.NewDecoder(&buf, tpls...).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, msg) {
+		t.Fatal("messages is not equal, got: ", decoded, ", expect: ", msg)
+	}
+}
+
+func TestParseXMLTemplateImplicitLength(t *testing.T) {
+	tpls, err := fast.ParseXMLTemplate(strings.NewReader(xmlImplicitLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	length := tpls[0].Instructions[0].Instructions[0]
+	if length.Type != fast.TypeLength || length.Presence != fast.PresenceOptional {
+		t.Fatal("expected optional length, got", length)
+	}
+}
+
+func TestWriteXMLTemplate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{string(data), xmlWrite} {
+		expect, err := fast.ParseXMLTemplate(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err = fast.WriteXMLTemplate(&buf, expect); err != nil {
+			t.Fatal(err)
+		}
+
+		current, err := fast.ParseXMLTemplate(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(current, expect) {
+			t.Fatal("templates are not equal after writing")
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 Alexander Poltoratskiy. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package fast
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// position is a position of element in XML.
+type position struct {
+	line, column int
+}
+
+type validator struct {
+	positions map[interface{}]position // positions of templates and instructions
+	byName    map[string]*Template
+	template  string // name of current template
+	errs      TemplateErrors
+}
+
+// Validate checks static errors of templates: duplicate ids and names, structure of
+// sequences, decimals, enums, sets and bit groups (ErrS1), operators not applicable to types (ErrS2), initial
+// values of other types (ErrS3), constants without initial value (ErrS4), mandatory
+// defaults without initial value (ErrS5) and static references to unknown templates
+// (ErrD8). All errors are returned as TemplateErrors.
+func Validate(templates ...*Template) error {
+	return validate(templates, nil)
+}
+
+func validate(templates []*Template, positions map[interface{}]position) error {
+	v := &validator{positions: positions, byName: make(map[string]*Template, len(templates))}
+
+	byID := make(map[uint]bool, len(templates))
+	for _, tpl := range templates {
+		v.template = tpl.Name
+		if tpl.ID != 0 && byID[tpl.ID] {
+			v.add(tpl, "", ErrS1, "duplicate template id "+strconv.FormatUint(uint64(tpl.ID), 10))
+		}
+		byID[tpl.ID] = true
+
+		if _, ok := v.byName[tpl.Name]; ok && tpl.Name != "" {
+			v.add(tpl, "", ErrS1, "duplicate template name")
+		}
+		v.byName[tpl.Name] = tpl
+	}
+
+	for _, tpl := range templates {
+		v.template = tpl.Name
+		v.checkInstructions(tpl.Instructions, "", TypeNull)
+		v.checkReferences(tpl.Instructions, map[string]bool{tpl.Name: true})
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// checkInstructions checks instructions of template, group, sequence or decimal.
+func (v *validator) checkInstructions(instructions []*Instruction, path string, parent InstructionType) {
+	names := make(map[string]bool, len(instructions))
+	for index, item := range instructions {
+		name := pathName(item)
+		if parent == TypeDecimal && item.Type == TypeExponent {
+			name = tagExponent
+		}
+		if parent == TypeDecimal && item.Type == TypeMantissa {
+			name = tagMantissa
+		}
+		itemPath := name
+		if path != "" {
+			itemPath = path + "." + name
+		}
+
+		if item.Name != "" && item.Type != TypeTemplateRef && parent != TypeDecimal {
+			if names[item.Name] {
+				v.add(item, itemPath, ErrS1, "duplicate field name")
+			}
+			names[item.Name] = true
+		}
+
+		v.checkInstruction(item, itemPath, parent, index)
+		v.checkInstructions(item.Instructions, itemPath, item.Type)
+	}
+}
+
+func (v *validator) checkInstruction(item *Instruction, path string, parent InstructionType, index int) {
+	switch item.Type {
+	case TypeNull:
+		v.add(item, path, ErrS1, "unknown type of instruction")
+	case TypeLength:
+		if parent != TypeSequence || index != 0 {
+			v.add(item, path, ErrS1, "length is not the first instruction of sequence")
+		}
+	case TypeExponent, TypeMantissa:
+		if parent != TypeDecimal {
+			v.add(item, path, ErrS1, "exponent or mantissa is not in decimal")
+		}
+	case TypeSequence:
+		if len(item.Instructions) == 0 || item.Instructions[0].Type != TypeLength {
+			v.add(item, path, ErrS1, "sequence has no length")
+		}
+	case TypeDecimal:
+		if len(item.Instructions) == 0 {
+			break
+		}
+		if item.Operator != OperatorNone {
+			v.add(item, path, ErrS1, "decimal has operator and individual operators")
+		}
+		if len(item.Instructions) != 2 ||
+			item.Instructions[0].Type != TypeExponent ||
+			item.Instructions[1].Type != TypeMantissa {
+			v.add(item, path, ErrS1, "decimal has to contain exponent and mantissa")
+		}
+	case TypeEnum, TypeSet:
+		v.checkElements(item, path)
+	case TypeBitGroup:
+		if len(item.Instructions) > 64 {
+			v.add(item, path, ErrS1, "bitGroup has more than 64 fields")
+		}
+		for _, inner := range item.Instructions {
+			if inner.Type != TypeBoolean || inner.Operator != OperatorNone || inner.isOptional() {
+				v.add(inner, path+"."+pathName(inner), ErrS1, "bitGroup field is not mandatory boolean without operator")
+			}
+		}
+	case TypeTemplateRef:
+		if _, ok := v.byName[item.Name]; !ok && item.Name != "" {
+			v.add(item, path, ErrD8, "unknown template "+item.Name)
+		}
+	}
+
+	if item.Unit != 0 && (unitName(item.Unit) == "" || item.Type != TypeTimestamp && item.Type != TypeTime) {
+		v.add(item, path, ErrS1, "invalid unit "+item.Unit.String())
+	}
+
+	if !item.isValid() {
+		v.add(item, path, ErrS2, "operator "+item.Operator.String()+" is not applicable")
+	}
+
+	if item.Value != nil && !item.isValidValue() {
+		v.add(item, path, ErrS3, fmt.Sprintf("initial value of type %T", item.Value))
+	}
+
+	if item.Operator == OperatorConstant && item.Value == nil {
+		v.add(item, path, ErrS4, "")
+	}
+
+	if item.Presence == PresenceMandatory &&
+		item.Operator == OperatorDefault &&
+		item.Value == nil {
+		v.add(item, path, ErrS5, "")
+	}
+}
+
+// checkElements checks elements of enum or set.
+func (v *validator) checkElements(item *Instruction, path string) {
+	if len(item.Elements) == 0 {
+		v.add(item, path, ErrS1, "no elements")
+	}
+	if item.Type == TypeSet && len(item.Elements) > 64 {
+		v.add(item, path, ErrS1, "set has more than 64 elements")
+	}
+
+	names := make(map[string]bool, len(item.Elements))
+	for _, name := range item.Elements {
+		if name == "" || names[name] {
+			v.add(item, path, ErrS1, fmt.Sprintf("invalid element name %q", name))
+		}
+		names[name] = true
+	}
+}
+
+// checkReferences reports templates which include themselves by static references.
+func (v *validator) checkReferences(instructions []*Instruction, path map[string]bool) {
+	for _, item := range instructions {
+		if item.Type != TypeTemplateRef || item.Name == "" {
+			v.checkReferences(item.Instructions, path)
+			continue
+		}
+
+		tpl, ok := v.byName[item.Name]
+		if !ok {
+			continue
+		}
+		if path[tpl.Name] {
+			v.add(item, "", ErrS1, "template "+tpl.Name+" includes itself")
+			continue
+		}
+
+		path[tpl.Name] = true
+		v.checkReferences(tpl.Instructions, path)
+		delete(path, tpl.Name)
+	}
+}
+
+func (v *validator) add(key interface{}, path string, err error, msg string) {
+	pos := v.positions[key]
+	v.errs = append(v.errs, &TemplateError{
+		Line:     pos.line,
+		Column:   pos.column,
+		Template: v.template,
+		Path:     path,
+		Msg:      msg,
+		Err:      err,
+	})
+}
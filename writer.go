@@ -5,110 +5,155 @@
 package fast
 
 import (
-	"bytes"
+	"fmt"
 	"io"
 )
 
 const (
-	maxSize32 = 4*8/7
-	maxSize64 = 8*8/7
+	maxSize64 = 10 // max count of bytes of 64 bit integer
+	maxSize32 = 5  // max count of bytes of 32 bit integer
 )
 
-type buffer interface {
-	io.Writer
-	io.WriterTo
-	Bytes() []byte
-	Reset()
+// writer encodes data to reusable buffer. Presence maps are reserved before data
+// of segment and patched when the segment is encoded.
+type writer struct {
+	buf []byte
+	log io.Writer // writes encoded bytes in hex, if it is not nil
 }
 
-type writer struct {
-	dataBuf buffer
-	pMapBuf buffer
+func newWriter() *writer {
+	return &writer{}
+}
 
-	strBuf bytes.Buffer
+func (w *writer) Bytes() []byte {
+	return w.buf
 }
 
-func newWriter(dataBuf, pMapBuf buffer) *writer {
-	return &writer{dataBuf: dataBuf, pMapBuf: pMapBuf}
+func (w *writer) Len() int {
+	return len(w.buf)
 }
 
-func (w *writer) Write(p []byte) (int, error) {
-	return w.dataBuf.Write(p)
+func (w *writer) Reset() {
+	w.buf = w.buf[:0]
 }
 
-func (w *writer) WriteTo(writer io.Writer) {
-	_, _ = w.pMapBuf.WriteTo(writer)
-	_, _ = w.dataBuf.WriteTo(writer)
+// logFrom logs bytes of buffer from position.
+func (w *writer) logFrom(pos int) {
+	if w.log != nil {
+		_, _ = fmt.Fprintf(w.log, "%x", w.buf[pos:])
+	}
 }
 
-func (w *writer) Reset() {
-	w.pMapBuf.Reset()
-	w.dataBuf.Reset()
+// ReservePMap reserves space for presence map of size bits and returns position of it.
+func (w *writer) ReservePMap(size int) int {
+	pos := len(w.buf)
+	for n := pMapBytes(size); n > 0; n-- {
+		w.buf = append(w.buf, 0)
+	}
+	return pos
 }
 
-func (w *writer) WritePMap(m *pMap) error {
-	var err error
-	if m.bitmap == 0 {
-		_, err = w.pMapBuf.Write([]byte{0x80})
-		return err
+// PatchPMap writes presence map to reserved space and removes unused bytes of it.
+func (w *writer) PatchPMap(pos, size int, m *pMap) {
+	reserved := pMapBytes(size)
+
+	// bits are stored from high to low in groups of 7 bits
+	n := 0
+	for i := 0; i < reserved; i++ {
+		b := byte(m.bitmap>>(uint(reserved-i-1)*7)) & 0x7F
+		w.buf[pos+i] = b
+		if b != 0 {
+			n = i + 1
+		}
 	}
+	if n == 0 {
+		n = 1 // empty presence map takes one byte
+	}
+	w.buf[pos+n-1] |= 0x80
+
+	if n < reserved {
+		copy(w.buf[pos+n:], w.buf[pos+reserved:])
+		w.buf = w.buf[:len(w.buf)-reserved+n]
+	}
+
+	if w.log != nil {
+		_, _ = fmt.Fprintf(w.log, "%x", w.buf[pos:pos+n])
+	}
+}
+
+// ReserveBlockLength reserves space for block length preamble and returns position of it.
+func (w *writer) ReserveBlockLength() int {
+	pos := len(w.buf)
+	w.buf = append(w.buf, make([]byte, maxSize32)...)
+	return pos
+}
+
+// PatchBlockLength writes count of bytes following the reserved space at the end of it
+// and returns position of the first byte of preamble.
+func (w *writer) PatchBlockLength(pos int) int {
+	value := len(w.buf) - pos - maxSize32
 
-	b := make([]byte, 8)
-	i :=7
-	for i >= 0 && m.bitmap != 0 {
-		b[i] = byte(m.bitmap)
-		m.bitmap >>= 7
+	i := pos + maxSize32 - 1
+	w.buf[i] = byte(value&0x7F) | 0x80
+	for value >>= 7; value != 0; value >>= 7 {
 		i--
+		w.buf[i] = byte(value & 0x7F)
 	}
-	b[7] |= 0x80
 
-	_, err = w.pMapBuf.Write(b[i+1:])
-	return err
+	if w.log != nil {
+		_, _ = fmt.Fprintf(w.log, "%x", w.buf[i:pos+maxSize32])
+	}
+	return i
 }
 
-func (w *writer) WriteUint(nullable bool, value uint64, size int) (err error) {
+func (w *writer) WriteUint(nullable bool, value uint64) error {
+	pos := len(w.buf)
+	defer w.logFrom(pos)
+
 	if !nullable && value == 0 {
-		_, err = w.dataBuf.Write([]byte{0x80})
-		return
+		w.buf = append(w.buf, 0x80)
+		return nil
 	}
 
-	if nullable && value > 0 {
+	if nullable {
 		value++
 	}
 
-	b := make([]byte, size+1)
-	i := size
+	var b [maxSize64]byte
+	i := maxSize64 - 1
 	for i >= 0 && value != 0 {
 		b[i] = byte(value & 0x7F)
 		value >>= 7
 		i--
 	}
 
-	b[size] |= 0x80
-	_, err = w.dataBuf.Write(b[i+1:])
-
-	return
+	b[maxSize64-1] |= 0x80
+	w.buf = append(w.buf, b[i+1:]...)
+	return nil
 }
 
-func (w *writer) WriteInt(nullable bool, value int64, size int) (err error) {
+func (w *writer) WriteInt(nullable bool, value int64) error {
+	pos := len(w.buf)
+	defer w.logFrom(pos)
+
 	if !nullable && value == 0 {
-		_, err = w.dataBuf.Write([]byte{0x80})
-		return
+		w.buf = append(w.buf, 0x80)
+		return nil
 	}
 
-	positive := value > 0
+	positive := value >= 0
 
 	if nullable && positive {
 		value++
 	}
 
 	var sign int64
-	if value <= 0 {
+	if !positive {
 		sign = -1
 	}
 
-	b := make([]byte, size+2)
-	i := size
+	var b [maxSize64 + 2]byte // the last byte is used if value equals sign
+	i := maxSize64
 	for i >= 0 && value != sign {
 		b[i] = byte(value & 0x7F)
 		value >>= 7
@@ -126,51 +171,71 @@ func (w *writer) WriteInt(nullable bool, value int64, size int) (err error) {
 		b[i] = 0x7F
 	}
 
-	b[size] |= 0x80
-	_, err = w.dataBuf.Write(b[i:size+1])
-	return
+	b[maxSize64] |= 0x80
+	w.buf = append(w.buf, b[i:maxSize64+1]...)
+	return nil
 }
 
-func (w *writer) WriteByteVector(nullable bool, value []byte) (err error) {
-	err = w.WriteUint(nullable, uint64(len(value)), maxSize32)
-	if err != nil {
-		return
+func (w *writer) WriteByteVector(nullable bool, value []byte) error {
+	if err := w.WriteUint(nullable, uint64(len(value))); err != nil {
+		return err
 	}
-	_, err = w.dataBuf.Write(value)
-	return
+
+	pos := len(w.buf)
+	w.buf = append(w.buf, value...)
+	w.logFrom(pos)
+	return nil
 }
 
-func (w *writer) WriteString(nullable bool, value string) (err error) {
+// WriteUnicodeString writes string as byte vector.
+func (w *writer) WriteUnicodeString(nullable bool, value string) error {
+	if err := w.WriteUint(nullable, uint64(len(value))); err != nil {
+		return err
+	}
+
+	pos := len(w.buf)
+	w.buf = append(w.buf, value...)
+	w.logFrom(pos)
+	return nil
+}
+
+func (w *writer) WriteString(nullable bool, value string) error {
+	pos := len(w.buf)
+	defer w.logFrom(pos)
+
 	if len(value) == 0 {
 		if nullable {
-			_, err = w.dataBuf.Write([]byte{0x00})
-			return
+			w.buf = append(w.buf, 0x00, 0x80)
+			return nil
 		}
-		_, err = w.dataBuf.Write([]byte{0x00})
-		return
+		w.buf = append(w.buf, 0x80)
+		return nil
 	}
 
 	if len(value) == 1 && value[0] == 0x00 {
 		if nullable {
-			_, err = w.dataBuf.Write([]byte{0x00, 0x00, 0x80})
+			w.buf = append(w.buf, 0x00, 0x00, 0x80)
 		} else {
-			_, err = w.dataBuf.Write([]byte{0x00, 0x80})
+			w.buf = append(w.buf, 0x00, 0x80)
 		}
-		return
-	}
-
-	w.strBuf.WriteString(value[:len(value)-1])
-	_, err = w.dataBuf.Write(w.strBuf.Bytes())
-	if err != nil {
-		return
+		return nil
 	}
 
-	w.strBuf.Reset()
-	_, err = w.dataBuf.Write([]byte{value[len(value)-1] | 0x80})
-	return
+	w.buf = append(w.buf, value[:len(value)-1]...)
+	w.buf = append(w.buf, value[len(value)-1]|0x80)
+	return nil
 }
 
 func (w *writer) WriteNil() error {
-	_, err := w.dataBuf.Write([]byte{0x80})
-	return err
+	w.buf = append(w.buf, 0x80)
+	w.logFrom(len(w.buf) - 1)
+	return nil
+}
+
+// pMapBytes returns count of bytes to encode presence map of size bits.
+func pMapBytes(size int) int {
+	if size == 0 {
+		return 1
+	}
+	return (size + 6) / 7
 }